@@ -0,0 +1,669 @@
+package main
+
+import (
+	"compress/flate"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/heptio/workgroup"
+	"go.uber.org/zap"
+
+	"github.com/webhookrelay/relay-go/pkg/client"
+	"github.com/webhookrelay/relay-go/pkg/config"
+	"github.com/webhookrelay/relay-go/pkg/dedup"
+	"github.com/webhookrelay/relay-go/pkg/forward"
+	"github.com/webhookrelay/relay-go/pkg/inspect"
+	"github.com/webhookrelay/relay-go/pkg/leader"
+	"github.com/webhookrelay/relay-go/pkg/logqueue"
+	"github.com/webhookrelay/relay-go/pkg/proxy"
+	"github.com/webhookrelay/relay-go/pkg/recorder"
+	"github.com/webhookrelay/relay-go/pkg/redact"
+	"github.com/webhookrelay/relay-go/pkg/respond"
+	"github.com/webhookrelay/relay-go/pkg/rewrite"
+	"github.com/webhookrelay/relay-go/pkg/sink"
+	"github.com/webhookrelay/relay-go/pkg/tlsconfig"
+)
+
+func runForward(logger *zap.SugaredLogger, serverAddress string) {
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Errorf("invalid configuration: %s", err)
+		os.Exit(1)
+	}
+
+	connections := cfg.Connections
+	if len(connections) == 0 {
+		connections = []*config.Connection{{
+			Key:     *key,
+			Secret:  *secret,
+			Buckets: sanitize(*buckets),
+		}}
+	}
+
+	for _, conn := range connections {
+		if conn.AccessKey() == "" {
+			conn.Key = *key
+		}
+		if conn.AccessSecret() == "" {
+			conn.Secret = *secret
+		}
+		if conn.ServerAddress == "" {
+			conn.ServerAddress = serverAddress
+		}
+		if conn.Retries == nil {
+			conn.Retries = retries
+		}
+		if conn.AccessKey() == "" || conn.AccessSecret() == "" {
+			logger.Errorf("--key and --secret flags must be set, alternatively use %s and %s environment variables. To create a token, visit https://my.webhookrelay.com/tokens", EnvRelayKey, EnvRelaySecret)
+			os.Exit(1)
+		}
+	}
+	logger.Info("forwarding..")
+
+	forwarderOpts, err := newForwarderOpts(cfg)
+	if err != nil {
+		logger.Errorf("invalid destination settings: %s", err)
+		os.Exit(1)
+	}
+	upstreamTLS, err := tlsconfig.New(&tlsconfig.Opts{
+		CAFile:     *serverCAFile,
+		MinVersion: *tlsMinVersion,
+	})
+	if err != nil {
+		logger.Errorf("invalid Webhook Relay server TLS settings: %s", err)
+		os.Exit(1)
+	}
+
+	upstreamProxy, err := newProxy(*upstreamProxyAddress)
+	if err != nil {
+		logger.Errorf("invalid upstream proxy: %s", err)
+		os.Exit(1)
+	}
+
+	if *wsCompressionLevel < flate.BestSpeed || *wsCompressionLevel > flate.BestCompression {
+		logger.Errorf("invalid websocket compression level %d", *wsCompressionLevel)
+		os.Exit(1)
+	}
+
+	redaction, err := redactionPolicy(cfg)
+	if err != nil {
+		logger.Errorf("invalid redaction rules: %s", err)
+		os.Exit(1)
+	}
+
+	var cache *dedup.Cache
+	if *dedupSize > 0 {
+		cache, err = dedup.New(&dedup.Opts{
+			Size: *dedupSize,
+			TTL:  *dedupTTL,
+			Path: *dedupFile,
+		})
+		if err != nil {
+			logger.Errorf("failed to initialise deduplication: %s", err)
+			os.Exit(1)
+		}
+		defer cache.Close()
+	}
+
+	var rec *recorder.Recorder
+	if *recordDir != "" {
+		rec, err = recorder.New(&recorder.Opts{
+			Dir:          *recordDir,
+			MaxFileSize:  int64(*recordMaxFileSize),
+			RotateEvery:  *recordRotateEvery,
+			MaxAge:       *recordMaxAge,
+			MaxTotalSize: int64(*recordMaxTotalSize),
+			Redaction:    redaction,
+			Logger:       logger.With("module", "recorder"),
+		})
+		if err != nil {
+			logger.Errorf("failed to initialise recording: %s", err)
+			os.Exit(1)
+		}
+		defer rec.Close()
+	}
+
+	rewriter, err := newRewriter(cfg)
+	if err != nil {
+		logger.Errorf("invalid destination rewrite rules: %s", err)
+		os.Exit(1)
+	}
+
+	var responder *respond.Responder
+	if *respondMode {
+		responder, err = newResponder(cfg, logger.With("module", "respond"))
+		if err != nil {
+			logger.Errorf("invalid mock responses: %s", err)
+			os.Exit(1)
+		}
+	}
+
+	var inspectBuffer *inspect.Buffer
+	if *uiAddr != "" {
+		inspectBuffer = inspect.NewBuffer(*uiEvents)
+	}
+
+	var relays []relayFunc
+
+	for _, conn := range connections {
+		connLogger := logger
+		if len(cfg.Connections) > 0 {
+			connLogger = logger.With("connection", conn.Name)
+		}
+
+		opts := *forwarderOpts
+		opts.Retries = *conn.Retries
+		opts.Insecure = *insecure || conn.Insecure
+		opts.Logger = connLogger.With("module", "forwarder")
+		forwarder := newForwarder(&opts, rewriter)
+		if responder != nil {
+			forwarder = responder
+		}
+		if *sinkURL != "" || len(cfg.Sinks) > 0 {
+			router, err := sink.NewRouter(&sink.Opts{
+				Default: forwarder,
+				Sink:    *sinkURL,
+				Buckets: cfg.Sinks,
+				Logger:  connLogger.With("module", "sink"),
+			})
+			if err != nil {
+				logger.Errorf("invalid sink: %s", err)
+				os.Exit(1)
+			}
+			defer router.Close()
+			forwarder = router
+		}
+		if rec != nil {
+			forwarder = recorder.NewForwarder(forwarder, rec)
+		}
+		if inspectBuffer != nil {
+			forwarder = inspect.NewForwarder(forwarder, inspectBuffer)
+		}
+
+		logQueue, err := newLogQueue(conn)
+		if err != nil {
+			logger.Errorf("failed to initialise log update queue: %s", err)
+			os.Exit(1)
+		}
+		defer logQueue.Close()
+
+		c := client.NewDefaultClient(&client.Opts{
+			AccessKey:           conn.AccessKey(),
+			AccessSecret:        conn.AccessSecret(),
+			InsecureSkipVerify:  *insecure || conn.Insecure,
+			Proxy:               upstreamProxy,
+			TLSConfig:           upstreamTLS,
+			Logger:              connLogger.With("module", "client"),
+			Forwarder:           forwarder,
+			ServerAddress:       conn.ServerAddress,
+			Dedup:               cache,
+			Debug:               *debug,
+			Redaction:           redaction,
+			Compression:         *wsCompression,
+			CompressionLevel:    *wsCompressionLevel,
+			GzipLogUpdates:      *gzipLogUpdates,
+			LogQueue:            logQueue,
+			WebsocketLogUpdates: *wsLogUpdates,
+		})
+
+		filter := client.Filter{
+			Buckets: conn.Buckets,
+		}
+
+		relays = append(relays, relayFunc{
+			logger: connLogger,
+			run: func(ctx context.Context) error {
+				return c.StartRelay(ctx, &filter)
+			},
+			health: c.Health,
+		})
+	}
+
+	var g workgroup.Group
+
+	if *haLock == "" {
+		addRelays(&g, relays)
+	} else {
+		lock, err := newLock(*haLock)
+		if err != nil {
+			logger.Errorf("invalid HA lock: %s", err)
+			os.Exit(1)
+		}
+		elector := leader.New(&leader.Opts{
+			Lock:          lock,
+			ID:            *haID,
+			LeaseDuration: *haLeaseDuration,
+			Logger:        logger.With("module", "leader"),
+		})
+
+		g.Add(func(stop <-chan struct{}) error {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				<-stop
+				cancel()
+			}()
+
+			var relayErr error
+			err := elector.Run(ctx, func(leaderCtx context.Context) {
+				// only the leader subscribes to buckets
+				var lg workgroup.Group
+				addRelays(&lg, relays)
+				lg.Add(func(stop <-chan struct{}) error {
+					select {
+					case <-leaderCtx.Done():
+					case <-stop:
+					}
+					return nil
+				})
+				if err := lg.Run(); err != nil {
+					relayErr = err
+					cancel()
+				}
+			})
+			if relayErr != nil {
+				return relayErr
+			}
+			return err
+		})
+	}
+
+	if inspectBuffer != nil {
+		srv := &http.Server{
+			Addr: *uiAddr,
+			Handler: inspect.NewServer(&inspect.ServerOpts{
+				Buffer:    inspectBuffer,
+				Addr:      *uiAddr,
+				Redaction: redaction,
+				Logger:    logger.With("module", "inspect"),
+			}),
+		}
+		g.Add(func(stop <-chan struct{}) error {
+			go func() {
+				<-stop
+				srv.Close()
+			}()
+			logger.Infof("inspection UI available on http://%s", *uiAddr)
+			if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+				return fmt.Errorf("inspection UI failed: %s", err)
+			}
+			return nil
+		})
+	}
+
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, os.Interrupt)
+	g.Add(func(stop <-chan struct{}) error {
+		select {
+		case <-signalChan:
+			logger.Info("received an interrupt, shutting down...")
+		case <-stop:
+		}
+		return nil
+	})
+
+	err = g.Run()
+	if err != nil {
+		logger.Errorf("forward exitted with an error: %s", err)
+		os.Exit(1)
+	}
+}
+
+// relayFunc - runs a single Webhook Relay connection until ctx is cancelled
+type relayFunc struct {
+	logger *zap.SugaredLogger
+	run    func(ctx context.Context) error
+	health func() client.Health
+}
+
+// healthCheckPeriod - how often connection health is checked
+var healthCheckPeriod = 10 * time.Second
+
+// addRelays - adds connections to the workgroup. Connections fail
+// independently and the group stops only when none of them are left
+func addRelays(g *workgroup.Group, relays []relayFunc) {
+	running := int32(len(relays))
+
+	for _, relay := range relays {
+		relay := relay
+		g.Add(func(stop <-chan struct{}) error {
+			defer relay.logger.Info("forwarding stopped")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go func() {
+				<-stop
+				cancel()
+			}()
+			if relay.health != nil {
+				go watchHealth(ctx, relay.logger, relay.health)
+			}
+
+			err := relay.run(ctx)
+			if err == nil {
+				return nil
+			}
+			relay.logger.Errorf("failed to start relay client: %s", err)
+			if atomic.AddInt32(&running, -1) == 0 {
+				return err
+			}
+			// other connections are still running
+			<-stop
+			return nil
+		})
+	}
+}
+
+// watchHealth - logs when connection becomes healthy (connected and
+// authenticated) or unhealthy, until ctx is cancelled
+func watchHealth(ctx context.Context, logger *zap.SugaredLogger, health func() client.Health) {
+	ticker := time.NewTicker(healthCheckPeriod)
+	defer ticker.Stop()
+
+	checked, wasHealthy := false, false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		h := health()
+		healthy := h.Connected && h.Authenticated
+		if checked && healthy == wasHealthy {
+			continue
+		}
+		checked, wasHealthy = true, healthy
+
+		if healthy {
+			logger.Infow("connection is healthy",
+				"reconnects", h.Reconnects,
+				"server_api_version", h.ServerAPIVersion,
+			)
+			continue
+		}
+		logger.Warnw("connection is unhealthy",
+			"connected", h.Connected,
+			"authenticated", h.Authenticated,
+			"reconnects", h.Reconnects,
+			"last_error", h.LastError,
+			"log_updates_pending", h.LogUpdatesPending,
+		)
+	}
+}
+
+// newLock - creates leader election lock backend from address such
+// as file:///mnt/shared/relayd.lease or memory://
+func newLock(address string) (leader.Lock, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "file":
+		path := u.Path
+		if u.Host != "" {
+			// relative path, e.g. file://data/relayd.lease
+			path = u.Host + u.Path
+		}
+		if path == "" {
+			return nil, fmt.Errorf("file lock requires a path, e.g. file:///mnt/shared/relayd.lease")
+		}
+		return leader.NewFileLock(path), nil
+	case "memory":
+		return leader.NewMemoryLock(), nil
+	}
+	return nil, fmt.Errorf("unsupported lock backend '%s', supported backends: file, memory", u.Scheme)
+}
+
+func sanitize(buckets string) []string {
+	parts := strings.Split(buckets, ",")
+	for idx, p := range parts {
+		parts[idx] = strings.TrimSpace(p)
+	}
+	return parts
+}
+
+// newLogQueue - creates delivery result queue for the connection,
+// persisted in --log-spool-dir when set
+func newLogQueue(conn *config.Connection) (*logqueue.Queue, error) {
+	opts := &logqueue.Opts{Size: *logQueueSize, MaxAge: *logMaxAge}
+	if *logSpoolDir != "" {
+		name := conn.Name
+		if name == "" {
+			name = "default"
+		}
+		err := os.MkdirAll(*logSpoolDir, 0700)
+		if err != nil {
+			return nil, err
+		}
+		opts.Path = filepath.Join(*logSpoolDir, name+".jsonl")
+	}
+	return logqueue.New(opts)
+}
+
+// list - splits comma separated values, skipping empty ones
+func list(values string) []string {
+	var out []string
+	for _, v := range strings.Split(values, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// newProxy - creates proxy function, connection specific address takes
+// precedence over --proxy flag and environment variables
+func newProxy(address string) (proxy.Func, error) {
+	if address == "" {
+		address = *proxyAddress
+	}
+	return proxy.New(&proxy.Opts{
+		URL:     address,
+		NoProxy: *noProxy,
+	})
+}
+
+// loadConfig - loads --config file, configuration is empty when the
+// flag is not set
+func loadConfig() (*config.Config, error) {
+	if *configFile == "" {
+		return &config.Config{}, nil
+	}
+	return config.Load(*configFile)
+}
+
+// forwardSinks - sink URLs from --sink and configuration file,
+// configuration errors are reported by runForward
+func forwardSinks() []string {
+	sinks := []string{*sinkURL}
+	if cfg, err := loadConfig(); err == nil {
+		for _, rawURL := range cfg.Sinks {
+			sinks = append(sinks, rawURL)
+		}
+	}
+	return sinks
+}
+
+// newForwarderOpts - destination settings shared by commands sending
+// webhooks to their destinations: TLS, TLS profiles, named destinations,
+// proxy, header policy and response reporting. Retries, Insecure and
+// Logger are set by the caller
+func newForwarderOpts(cfg *config.Config) (*forward.Opts, error) {
+	destinationTLS, err := tlsconfig.New(&tlsconfig.Opts{
+		CAFile:     *caFile,
+		CertFile:   *certFile,
+		KeyFile:    *keyFile,
+		ServerName: *tlsServerName,
+		MinVersion: *tlsMinVersion,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination TLS settings: %s", err)
+	}
+	hostTLS, err := destinationTLSConfigs(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination TLS settings: %s", err)
+	}
+	destinations, err := namedDestinations(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid destinations: %s", err)
+	}
+	destinationProxy, err := newProxy(*destinationProxyAddress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination proxy: %s", err)
+	}
+
+	headerPolicy := &forward.HeaderPolicy{}
+	if *sanitizeHeaders {
+		headerPolicy = forward.DefaultHeaderPolicy()
+	}
+	if *allowHeaders != "" {
+		headerPolicy.Allow = sanitize(*allowHeaders)
+	}
+	if *denyHeaders != "" {
+		headerPolicy.Deny = append(headerPolicy.Deny, sanitize(*denyHeaders)...)
+	}
+
+	responseRedactor, err := redact.New(&redact.Rules{
+		Headers: list(*redactResponseHeader),
+		Fields:  list(*redactResponseFields),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid response redaction rules: %s", err)
+	}
+
+	return &forward.Opts{
+		Proxy:               destinationProxy,
+		TLSConfig:           destinationTLS,
+		HostTLSConfigs:      hostTLS,
+		Destinations:        destinations,
+		AllowUnixSockets:    *allowUnixSocket,
+		IdempotencyHeaders:  *idempotencyHeaders,
+		MetadataHeaders:     *metadataHeaders,
+		HeaderPolicy:        headerPolicy,
+		MaxResponseBody:     int64(*maxResponseBody),
+		DiscardResponseBody: *discardResponseBody,
+		ResponseRedactor:    responseRedactor,
+	}, nil
+}
+
+// newForwarder - creates destination forwarder, rewriting destinations
+// when rewriter is set
+func newForwarder(opts *forward.Opts, rewriter *rewrite.Rewriter) forward.Forwarder {
+	defaultForwarder := forward.NewDefaultForwarder(opts)
+	if rewriter == nil {
+		return defaultForwarder
+	}
+	return rewrite.NewForwarder(defaultForwarder, rewriter)
+}
+
+// destinationTLSConfigs - builds TLS configs for destination hosts
+// from configuration file profiles
+func destinationTLSConfigs(cfg *config.Config) (map[string]*tls.Config, error) {
+	profiles := make(map[string]*tls.Config, len(cfg.TLSProfiles))
+	for name, opts := range cfg.TLSProfiles {
+		tlsConfig, err := tlsconfig.New(opts)
+		if err != nil {
+			return nil, fmt.Errorf("tls profile '%s': %s", name, err)
+		}
+		profiles[name] = tlsConfig
+	}
+
+	hosts := make(map[string]*tls.Config, len(cfg.DestinationTLS))
+	for _, d := range cfg.DestinationTLS {
+		hosts[d.Host] = profiles[d.Profile]
+	}
+	return hosts, nil
+}
+
+// namedDestinations - builds named destinations from configuration file
+func namedDestinations(cfg *config.Config) (map[string]*forward.Destination, error) {
+	destinations := make(map[string]*forward.Destination, len(cfg.Destinations))
+	for name, d := range cfg.Destinations {
+		fd := &forward.Destination{
+			URL:      d.URL,
+			Insecure: d.Insecure,
+			Timeout:  d.Timeout,
+		}
+		if d.TLSProfile != "" {
+			tlsConfig, err := tlsconfig.New(cfg.TLSProfiles[d.TLSProfile])
+			if err != nil {
+				return nil, fmt.Errorf("destination '%s': %s", name, err)
+			}
+			fd.TLSConfig = tlsConfig
+		}
+		if d.Proxy != "" {
+			p, err := newProxy(d.Proxy)
+			if err != nil {
+				return nil, fmt.Errorf("destination '%s': invalid proxy: %s", name, err)
+			}
+			fd.Proxy = p
+		}
+		destinations[name] = fd
+	}
+	return destinations, nil
+}
+
+// redactionPolicy - combines redaction flags with configuration file rules
+func redactionPolicy(cfg *config.Config) (*redact.Policy, error) {
+	rc := &redact.Config{}
+	if cfg.Redaction != nil {
+		*rc = *cfg.Redaction
+	}
+	rc.Headers = append(list(*redactHeaders), rc.Headers...)
+	rc.Fields = append(list(*redactFields), rc.Fields...)
+	rc.Paths = append(list(*redactPaths), rc.Paths...)
+	rc.Patterns = append(append([]string{}, *redactPatterns...), rc.Patterns...)
+	rc.CardNumbers = rc.CardNumbers || *redactCardNumbers
+	return redact.NewPolicy(rc)
+}
+
+// newRewriter - creates destination rewriter from configuration file
+// rules, --rewrite-destination is the last rule matching all webhooks.
+// Returns nil when there are no rules
+func newRewriter(cfg *config.Config) (*rewrite.Rewriter, error) {
+	if *rewriteDestination == "" && len(cfg.Rewrite) == 0 {
+		return nil, nil
+	}
+	rules := cfg.Rewrite
+	if *rewriteDestination != "" {
+		rules = append(rules, &rewrite.Rule{Destination: *rewriteDestination})
+	}
+	return rewrite.New(&rewrite.Opts{Rules: rules})
+}
+
+// newResponder - mock responder with configured routes and a catch-all
+// route from command line flags
+func newResponder(cfg *config.Config, logger *zap.SugaredLogger) (*respond.Responder, error) {
+	headers := make(map[string]string)
+	for _, h := range *respondHeaders {
+		parts := strings.SplitN(h, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid header '%s', expected Name: value", h)
+		}
+		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	routes := append(cfg.Respond, &respond.Route{
+		Status:      *respondStatus,
+		Headers:     headers,
+		Body:        *respondBody,
+		Latency:     *respondLatency,
+		FailureRate: *respondFailureRate,
+	})
+	return respond.New(&respond.Opts{
+		Routes: routes,
+		Logger: logger,
+	})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+
+	"go.uber.org/zap"
+
+	"github.com/webhookrelay/relay-go/pkg/replay"
+)
+
+func runReplay(logger *zap.SugaredLogger) {
+	if *replayRate < 0 || *replaySpeed < 0 {
+		logger.Errorf("--rate and --speed cannot be negative")
+		os.Exit(1)
+	}
+
+	var in io.Reader = os.Stdin
+	if *replayFile != "-" {
+		f, err := os.Open(*replayFile)
+		if err != nil {
+			logger.Errorf("failed to open recorded events: %s", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Errorf("invalid configuration: %s", err)
+		os.Exit(1)
+	}
+	opts, err := newForwarderOpts(cfg)
+	if err != nil {
+		logger.Errorf("invalid destination settings: %s", err)
+		os.Exit(1)
+	}
+	opts.Retries = *replayRetries
+	opts.Insecure = *replayInsecure
+	opts.Logger = logger.With("module", "forwarder")
+	rewriter, err := newRewriter(cfg)
+	if err != nil {
+		logger.Errorf("invalid destination rewrite rules: %s", err)
+		os.Exit(1)
+	}
+	forwarder := newForwarder(opts, rewriter)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt)
+		<-signals
+		logger.Info("interrupted, waiting for events in flight")
+		cancel()
+	}()
+
+	report, err := replay.Run(ctx, replay.NewReader(in), &replay.Opts{
+		Forwarder:   forwarder,
+		Rate:        *replayRate,
+		Concurrency: *replayConcurrency,
+		Destination: *replayDestination,
+		Speed:       *replaySpeed,
+	})
+	if werr := report.Write(os.Stdout); werr != nil {
+		logger.Errorf("failed to write results: %s", werr)
+	}
+	if err != nil && err != context.Canceled {
+		logger.Errorf("replay stopped: %s", err)
+		os.Exit(1)
+	}
+	if report.Failed() > 0 {
+		os.Exit(1)
+	}
+}
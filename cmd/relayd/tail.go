@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"go.uber.org/zap"
+
+	"github.com/webhookrelay/relay-go/pkg/client"
+	"github.com/webhookrelay/relay-go/pkg/forward"
+	"github.com/webhookrelay/relay-go/pkg/tail"
+)
+
+func runTail(logger *zap.SugaredLogger, serverAddress string) {
+	if *key == "" || *secret == "" {
+		logger.Errorf("--key and --secret flags must be set, alternatively use %s and %s environment variables. To create a token, visit https://my.webhookrelay.com/tokens", EnvRelayKey, EnvRelaySecret)
+		os.Exit(1)
+	}
+
+	upstreamProxy, err := newProxy(*upstreamProxyAddress)
+	if err != nil {
+		logger.Errorf("invalid upstream proxy: %s", err)
+		os.Exit(1)
+	}
+
+	var forwarder forward.Forwarder
+	if *tailForward {
+		cfg, err := loadConfig()
+		if err != nil {
+			logger.Errorf("invalid configuration: %s", err)
+			os.Exit(1)
+		}
+		opts, err := newForwarderOpts(cfg)
+		if err != nil {
+			logger.Errorf("invalid destination settings: %s", err)
+			os.Exit(1)
+		}
+		opts.Retries = 3
+		opts.Insecure = *insecure
+		opts.Logger = logger.With("module", "forwarder")
+		rewriter, err := newRewriter(cfg)
+		if err != nil {
+			logger.Errorf("invalid destination rewrite rules: %s", err)
+			os.Exit(1)
+		}
+		forwarder = newForwarder(opts, rewriter)
+	}
+
+	printer, err := tail.NewPrinter(&tail.Opts{
+		Out:       os.Stdout,
+		Format:    *tailFormat,
+		Headers:   *tailHeaders,
+		Body:      *tailBody,
+		Extract:   *tailExtract,
+		Forwarder: forwarder,
+	})
+	if err != nil {
+		logger.Errorf("invalid tail options: %s", err)
+		os.Exit(1)
+	}
+
+	c := client.NewDefaultClient(&client.Opts{
+		AccessKey:           *key,
+		AccessSecret:        *secret,
+		Proxy:               upstreamProxy,
+		Logger:              logger.With("module", "client"),
+		Forwarder:           printer,
+		ServerAddress:       serverAddress,
+		Debug:               *debug,
+		Compression:         true,
+		GzipLogUpdates:      true,
+		WebsocketLogUpdates: true,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt)
+		<-signals
+		cancel()
+	}()
+
+	err = c.StartRelay(ctx, &client.Filter{Buckets: sanitize(*tailBuckets)})
+	if err != nil && err != context.Canceled {
+		logger.Errorf("tail exitted with an error: %s", err)
+		os.Exit(1)
+	}
+}
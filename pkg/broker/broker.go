@@ -0,0 +1,154 @@
+// Package broker publishes webhooks to message brokers, bridging SaaS
+// webhooks into an internal event bus.
+//
+// Messages are JSON envelopes (version 1):
+//
+//	{
+//	  "version": 1,
+//	  "published_at": "2020-01-02T15:04:05.000000001Z",
+//	  "event": {...}
+//	}
+//
+// event is types.Event as streamed by Webhook Relay. Fields are only ever
+// added within a version, consumers should ignore unknown fields.
+package broker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"text/template"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/webhookrelay/relay-go/pkg/forward"
+	"github.com/webhookrelay/relay-go/pkg/logger"
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// EnvelopeVersion - message envelope format version
+const EnvelopeVersion = 1
+
+// DefaultTimeout - default time to wait for broker acknowledgement
+var DefaultTimeout = 10 * time.Second
+
+// Envelope - published message
+type Envelope struct {
+	Version     int          `json:"version"`
+	PublishedAt time.Time    `json:"published_at"`
+	Event       *types.Event `json:"event"`
+}
+
+// Publisher - broker client
+type Publisher interface {
+	// Publish - publishes message to subject, topic or stream and waits
+	// until broker acknowledges it, returns broker specific
+	// acknowledgement such as stream sequence
+	Publish(ctx context.Context, target string, msg []byte) (ack string, err error)
+	Close() error
+}
+
+// Opts - broker forwarder configuration
+type Opts struct {
+	Publisher Publisher
+	// Target - subject, topic or stream template, event metadata is
+	// available as in "webhooks.{{ .BucketName }}"
+	Target string
+	// Timeout - how long to wait for acknowledgement
+	Timeout time.Duration
+	Logger  *zap.SugaredLogger
+}
+
+var _ forward.Forwarder = &Forwarder{}
+
+// Forwarder - forwarder that publishes webhooks to a broker
+type Forwarder struct {
+	publisher Publisher
+	target    *template.Template
+	timeout   time.Duration
+	logger    *zap.SugaredLogger
+}
+
+// NewForwarder - creates broker forwarder, returns an error for invalid
+// target template
+func NewForwarder(opts *Opts) (*Forwarder, error) {
+	if opts.Target == "" {
+		return nil, fmt.Errorf("target is required")
+	}
+	target, err := template.New("target").Option("missingkey=error").Parse(opts.Target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target template: %s", err)
+	}
+	if err := target.Execute(&bytes.Buffer{}, &types.EventMeta{}); err != nil {
+		return nil, fmt.Errorf("invalid target template: %s", err)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Logger == nil {
+		opts.Logger = logger.GetLoggerInstance(logger.DefaultLogLevel).Sugar()
+	}
+
+	return &Forwarder{
+		publisher: opts.Publisher,
+		target:    target,
+		timeout:   opts.Timeout,
+		logger:    opts.Logger,
+	}, nil
+}
+
+// Forward - publishes webhook, acknowledged messages are reported as sent
+func (f *Forwarder) Forward(wh types.Event) (*types.LogUpdateRequest, error) {
+	var target bytes.Buffer
+	if err := f.target.Execute(&target, &wh.Meta); err != nil {
+		return failed(&wh, fmt.Errorf("failed to render target: %s", err)), nil
+	}
+
+	msg, err := json.Marshal(&Envelope{
+		Version:     EnvelopeVersion,
+		PublishedAt: time.Now().UTC(),
+		Event:       &wh,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal envelope: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	defer cancel()
+
+	ack, err := f.publisher.Publish(ctx, target.String(), msg)
+	if err != nil {
+		f.logger.Warnw("failed to publish webhook",
+			"id", wh.Meta.ID,
+			"target", target.String(),
+			"error", err,
+		)
+		return failed(&wh, err), nil
+	}
+
+	f.logger.Infow("webhook published",
+		"id", wh.Meta.ID,
+		"target", target.String(),
+	)
+	return &types.LogUpdateRequest{
+		ID:           wh.Meta.ID,
+		StatusCode:   200,
+		Status:       types.RequestStatusSent,
+		ResponseBody: []byte(fmt.Sprintf("published to %s: %s", target.String(), ack)),
+	}, nil
+}
+
+// Close - closes publisher
+func (f *Forwarder) Close() error {
+	return f.publisher.Close()
+}
+
+func failed(wh *types.Event, err error) *types.LogUpdateRequest {
+	return &types.LogUpdateRequest{
+		ID:           wh.Meta.ID,
+		Status:       types.RequestStatusFailed,
+		ResponseBody: []byte(fmt.Sprintf("publish failed: %s", err)),
+	}
+}
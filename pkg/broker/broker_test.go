@@ -0,0 +1,423 @@
+package broker
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// published - message received by a fake broker
+type published struct {
+	target string
+	data   []byte
+}
+
+// fakeNATS - in-process NATS server supporting PUB, SUB and PING
+type fakeNATS struct {
+	net.Listener
+	// jetstream - acknowledge messages with reply subjects like JetStream
+	jetstream bool
+	// noResponders - answer requests with 503 status
+	noResponders bool
+	// tlsConfig - require TLS with this server configuration
+	tlsConfig *tls.Config
+
+	mu       sync.Mutex
+	messages []published
+	connects []string
+}
+
+func newFakeNATS(t *testing.T) *fakeNATS {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	s := &fakeNATS{Listener: l}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeNATS) serve(conn net.Conn) {
+	defer conn.Close()
+	s.mu.Lock()
+	tlsConfig := s.tlsConfig
+	s.mu.Unlock()
+	if tlsConfig == nil {
+		io.WriteString(conn, "INFO {\"server_id\":\"fake\",\"headers\":true}\r\n")
+	} else {
+		io.WriteString(conn, "INFO {\"server_id\":\"fake\",\"headers\":true,\"tls_required\":true}\r\n")
+		tlsConn := tls.Server(conn, tlsConfig)
+		defer tlsConn.Close()
+		conn = tlsConn
+	}
+	r := bufio.NewReader(conn)
+
+	var seq int
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "CONNECT":
+			s.mu.Lock()
+			s.connects = append(s.connects, strings.TrimSpace(strings.TrimPrefix(line, "CONNECT")))
+			s.mu.Unlock()
+		case "PING":
+			io.WriteString(conn, "PONG\r\n")
+		case "PUB":
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			data := make([]byte, size+2)
+			io.ReadFull(r, data)
+
+			s.mu.Lock()
+			s.messages = append(s.messages, published{target: fields[1], data: data[:size]})
+			jetstream, noResponders := s.jetstream, s.noResponders
+			s.mu.Unlock()
+
+			if len(fields) != 4 {
+				continue
+			}
+			reply := fields[2]
+			switch {
+			case noResponders:
+				headers := "NATS/1.0 503\r\n\r\n"
+				fmt.Fprintf(conn, "HMSG %s 1 %d %d\r\n%s\r\n", reply, len(headers), len(headers), headers)
+			case jetstream:
+				seq++
+				ack := fmt.Sprintf(`{"stream":"WEBHOOKS","seq":%d}`, seq)
+				fmt.Fprintf(conn, "MSG %s 1 %d\r\n%s\r\n", reply, len(ack), ack)
+			}
+		}
+	}
+}
+
+func (s *fakeNATS) published() []published {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]published(nil), s.messages...)
+}
+
+func newTestForwarder(t *testing.T, rawURL string) *Forwarder {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("invalid URL: %s", err)
+	}
+	f, err := New(u, nil)
+	if err != nil {
+		t.Fatalf("failed to create forwarder: %s", err)
+	}
+	return f
+}
+
+func TestNATSPublish(t *testing.T) {
+	srv := newFakeNATS(t)
+	defer srv.Close()
+
+	f := newTestForwarder(t, "nats://user:pass@"+srv.Addr().String()+"?subject=webhooks.{{.BucketName}}&timeout=2s")
+	defer f.Close()
+
+	resp, err := f.Forward(types.Event{
+		Method: "POST",
+		Body:   `{"hello":"world"}`,
+		Meta:   types.EventMeta{ID: "1", BucketName: "github"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if resp.Status != types.RequestStatusSent || resp.StatusCode != 200 {
+		t.Errorf("unexpected result: %+v", resp)
+	}
+
+	msgs := srv.published()
+	if len(msgs) != 1 || msgs[0].target != "webhooks.github" {
+		t.Fatalf("unexpected messages: %v", msgs)
+	}
+	var envelope Envelope
+	if err := json.Unmarshal(msgs[0].data, &envelope); err != nil {
+		t.Fatalf("failed to unmarshal envelope: %s", err)
+	}
+	if envelope.Version != EnvelopeVersion || envelope.Event.Meta.ID != "1" || envelope.Event.Body != `{"hello":"world"}` {
+		t.Errorf("unexpected envelope: %+v", envelope)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if !strings.Contains(srv.connects[0], `"user":"user"`) || !strings.Contains(srv.connects[0], `"pass":"pass"`) {
+		t.Errorf("expected credentials in CONNECT, got %s", srv.connects[0])
+	}
+}
+
+func TestNATSJetStream(t *testing.T) {
+	srv := newFakeNATS(t)
+	defer srv.Close()
+	srv.mu.Lock()
+	srv.jetstream = true
+	srv.mu.Unlock()
+
+	f := newTestForwarder(t, "nats://"+srv.Addr().String()+"?subject=webhooks&jetstream=true")
+	defer f.Close()
+
+	for _, seq := range []string{"1", "2"} {
+		resp, _ := f.Forward(types.Event{Method: "POST", Meta: types.EventMeta{ID: seq, BucketName: "github"}})
+		if resp.Status != types.RequestStatusSent || !strings.Contains(string(resp.ResponseBody), "sequence "+seq) {
+			t.Errorf("unexpected result: %d %s", resp.Status, resp.ResponseBody)
+		}
+	}
+
+	srv.mu.Lock()
+	srv.noResponders = true
+	srv.mu.Unlock()
+	resp, _ := f.Forward(types.Event{Method: "POST", Meta: types.EventMeta{ID: "3", BucketName: "github"}})
+	if resp.Status != types.RequestStatusFailed || !strings.Contains(string(resp.ResponseBody), "no JetStream stream") {
+		t.Errorf("expected missing stream to fail, got %d %s", resp.Status, resp.ResponseBody)
+	}
+}
+
+func TestNATSUnavailable(t *testing.T) {
+	srv := newFakeNATS(t)
+	addr := srv.Addr().String()
+	srv.Close()
+
+	f := newTestForwarder(t, "nats://"+addr+"?subject=webhooks&timeout=1s")
+	resp, err := f.Forward(types.Event{Method: "POST", Meta: types.EventMeta{ID: "1", BucketName: "github"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if resp.Status != types.RequestStatusFailed {
+		t.Errorf("expected failed status, got %+v", resp)
+	}
+}
+
+func TestNATSTLS(t *testing.T) {
+	// certificate for 127.0.0.1 to serve NATS with
+	ts := httptest.NewTLSServer(nil)
+	defer ts.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+
+	srv := newFakeNATS(t)
+	defer srv.Close()
+	srv.mu.Lock()
+	srv.tlsConfig = &tls.Config{Certificates: ts.TLS.Certificates}
+	srv.mu.Unlock()
+
+	n := NewNATS(&NATSOpts{Address: srv.Addr().String(), TLSConfig: &tls.Config{RootCAs: roots}})
+	defer n.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := n.Publish(ctx, "webhooks", []byte("{}")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.messages) != 1 || !strings.Contains(srv.connects[0], `"tls_required":true`) {
+		t.Errorf("expected message published over TLS, got %v, %v", srv.messages, srv.connects)
+	}
+}
+
+func TestNATSTLSUnavailable(t *testing.T) {
+	srv := newFakeNATS(t)
+	defer srv.Close()
+
+	f := newTestForwarder(t, "nats://"+srv.Addr().String()+"?subject=webhooks&tls=true&timeout=1s")
+	defer f.Close()
+	resp, _ := f.Forward(types.Event{Method: "POST", Meta: types.EventMeta{ID: "1"}})
+	if resp.Status != types.RequestStatusFailed || !strings.Contains(string(resp.ResponseBody), "does not support it") {
+		t.Errorf("expected TLS to be required, got %d %s", resp.Status, resp.ResponseBody)
+	}
+}
+
+// fakeRedis - in-process Redis server supporting AUTH, SELECT and XADD
+type fakeRedis struct {
+	net.Listener
+	password string
+
+	mu       sync.Mutex
+	commands [][]string
+}
+
+// newFakeRedis - starts fake Redis server, over TLS when tlsConfig is set
+func newFakeRedis(t *testing.T, password string, tlsConfig *tls.Config) *fakeRedis {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	if tlsConfig != nil {
+		l = tls.NewListener(l, tlsConfig)
+	}
+	s := &fakeRedis{Listener: l, password: password}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	authenticated := s.password == ""
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, n)
+		for i := range args {
+			sizeLine, _ := r.ReadString('\n')
+			size, _ := strconv.Atoi(strings.TrimSpace(sizeLine[1:]))
+			data := make([]byte, size+2)
+			io.ReadFull(r, data)
+			args[i] = string(data[:size])
+		}
+
+		s.mu.Lock()
+		s.commands = append(s.commands, args)
+		id := len(s.commands)
+		s.mu.Unlock()
+
+		switch {
+		case args[0] == "AUTH" && args[len(args)-1] == s.password:
+			authenticated = true
+			io.WriteString(conn, "+OK\r\n")
+		case args[0] == "AUTH":
+			io.WriteString(conn, "-WRONGPASS invalid password\r\n")
+		case !authenticated:
+			io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+		case args[0] == "SELECT":
+			io.WriteString(conn, "+OK\r\n")
+		case args[0] == "XADD":
+			entry := fmt.Sprintf("%d-0", time.Now().Unix()*1000+int64(id))
+			fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(entry), entry)
+		default:
+			io.WriteString(conn, "-ERR unknown command\r\n")
+		}
+	}
+}
+
+func TestRedisPublish(t *testing.T) {
+	srv := newFakeRedis(t, "secret", nil)
+	defer srv.Close()
+
+	f := newTestForwarder(t, "redis://:secret@"+srv.Addr().String()+"?stream=webhooks:{{.BucketName}}&maxlen=1000&db=2")
+	defer f.Close()
+
+	resp, err := f.Forward(types.Event{Method: "POST", Meta: types.EventMeta{ID: "1", BucketName: "stripe"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if resp.Status != types.RequestStatusSent || !strings.Contains(string(resp.ResponseBody), "entry ") {
+		t.Errorf("unexpected result: %d %s", resp.Status, resp.ResponseBody)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.commands) != 3 || srv.commands[1][0] != "SELECT" || srv.commands[1][1] != "2" {
+		t.Fatalf("unexpected commands: %v", srv.commands)
+	}
+	xadd := srv.commands[2]
+	expected := []string{"XADD", "webhooks:stripe", "MAXLEN", "~", "1000", "*", "data"}
+	if strings.Join(xadd[:len(expected)], " ") != strings.Join(expected, " ") {
+		t.Errorf("unexpected XADD arguments: %v", xadd[:len(xadd)-1])
+	}
+	var envelope Envelope
+	if err := json.Unmarshal([]byte(xadd[len(xadd)-1]), &envelope); err != nil || envelope.Event.Meta.ID != "1" {
+		t.Errorf("unexpected envelope: %s, %v", xadd[len(xadd)-1], err)
+	}
+}
+
+func TestRedisAuthFailure(t *testing.T) {
+	srv := newFakeRedis(t, "secret", nil)
+	defer srv.Close()
+
+	f := newTestForwarder(t, "redis://:wrong@"+srv.Addr().String()+"?stream=webhooks")
+	resp, _ := f.Forward(types.Event{Method: "POST", Meta: types.EventMeta{ID: "1", BucketName: "stripe"}})
+	if resp.Status != types.RequestStatusFailed || !strings.Contains(string(resp.ResponseBody), "WRONGPASS") {
+		t.Errorf("expected authentication failure, got %d %s", resp.Status, resp.ResponseBody)
+	}
+}
+
+func TestRedisTLS(t *testing.T) {
+	// certificate for 127.0.0.1 to serve Redis with
+	ts := httptest.NewTLSServer(nil)
+	defer ts.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+
+	srv := newFakeRedis(t, "secret", &tls.Config{Certificates: ts.TLS.Certificates})
+	defer srv.Close()
+
+	r := NewRedis(&RedisOpts{Address: srv.Addr().String(), Password: "secret", TLSConfig: &tls.Config{RootCAs: roots}})
+	defer r.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := r.Publish(ctx, "webhooks", []byte("{}")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// certificate is not trusted without the CA
+	f := newTestForwarder(t, "rediss://:secret@"+srv.Addr().String()+"?stream=webhooks")
+	defer f.Close()
+	resp, _ := f.Forward(types.Event{Method: "POST", Meta: types.EventMeta{ID: "1"}})
+	if resp.Status != types.RequestStatusFailed || !strings.Contains(string(resp.ResponseBody), "TLS handshake failed") {
+		t.Errorf("expected TLS verification failure, got %d %s", resp.Status, resp.ResponseBody)
+	}
+
+	f = newTestForwarder(t, "rediss://:secret@"+srv.Addr().String()+"?stream=webhooks&insecure=true")
+	defer f.Close()
+	resp, _ = f.Forward(types.Event{Method: "POST", Meta: types.EventMeta{ID: "2"}})
+	if resp.Status != types.RequestStatusSent {
+		t.Errorf("unexpected result: %d %s", resp.Status, resp.ResponseBody)
+	}
+}
+
+func TestInvalidURLs(t *testing.T) {
+	for _, rawURL := range []string{
+		"nats://localhost",
+		"nats://localhost?subject={{.Unknown}}",
+		"nats://localhost?subject=a&jetstream=maybe",
+		"redis://localhost?stream=a&maxlen=-1",
+		"rediss://localhost?stream=a&insecure=maybe",
+		"kafka://localhost?topic=webhooks",
+		"amqp://localhost",
+	} {
+		u, _ := url.Parse(rawURL)
+		if _, err := New(u, nil); err == nil {
+			t.Errorf("expected an error for %s", rawURL)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package broker
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/webhookrelay/relay-go/version"
+)
+
+// NATSOpts - NATS publisher configuration
+type NATSOpts struct {
+	// Address - server host:port
+	Address string
+	// User and Password or Token - optional credentials
+	User, Password, Token string
+	// JetStream - wait for JetStream acknowledgements instead of server
+	// flush, requires a stream bound to published subjects
+	JetStream bool
+	// TLS - require TLS even if server doesn't ask for it. TLS is always
+	// used when server requires it
+	TLS bool
+	// TLSConfig - optional TLS settings, defaults to system roots
+	TLSConfig *tls.Config
+}
+
+var _ Publisher = &NATS{}
+
+// NATS - minimal NATS client that publishes messages one at a time,
+// reconnecting when connection fails
+type NATS struct {
+	opts *NATSOpts
+
+	mu    sync.Mutex
+	conn  net.Conn
+	r     *bufio.Reader
+	inbox string
+	seq   uint64
+}
+
+// NewNATS - creates NATS publisher, connection is established on first
+// publish
+func NewNATS(opts *NATSOpts) *NATS {
+	return &NATS{opts: opts}
+}
+
+// Publish - publishes message and waits for server flush or JetStream
+// acknowledgement
+func (n *NATS) Publish(ctx context.Context, subject string, msg []byte) (string, error) {
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n") {
+		return "", fmt.Errorf("invalid subject '%s'", subject)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.conn == nil {
+		if err := n.connect(ctx); err != nil {
+			return "", err
+		}
+	}
+	ack, err := n.publish(ctx, subject, msg)
+	if err != nil {
+		n.close()
+	}
+	return ack, err
+}
+
+// Close - closes connection
+func (n *NATS) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.close()
+}
+
+func (n *NATS) close() error {
+	if n.conn == nil {
+		return nil
+	}
+	err := n.conn.Close()
+	n.conn, n.r = nil, nil
+	return err
+}
+
+func (n *NATS) connect(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", n.opts.Address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS: %s", err)
+	}
+	n.conn = conn
+	n.r = bufio.NewReader(conn)
+	setDeadline(ctx, conn)
+
+	line, err := n.readLine()
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		n.close()
+		return fmt.Errorf("unexpected NATS greeting: %q, %v", line, err)
+	}
+	var info struct {
+		TLSRequired  bool `json:"tls_required"`
+		TLSAvailable bool `json:"tls_available"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info); err != nil {
+		n.close()
+		return fmt.Errorf("invalid NATS server info: %s", err)
+	}
+
+	useTLS := info.TLSRequired || n.opts.TLS
+	if useTLS {
+		if !info.TLSRequired && !info.TLSAvailable {
+			n.close()
+			return fmt.Errorf("TLS was requested but NATS server does not support it")
+		}
+		if err := n.upgradeTLS(); err != nil {
+			n.close()
+			return fmt.Errorf("NATS TLS handshake failed: %s", err)
+		}
+	}
+
+	connect, _ := json.Marshal(map[string]interface{}{
+		"verbose":       false,
+		"pedantic":      false,
+		"name":          "relayd",
+		"lang":          "go",
+		"version":       version.GetWebhookRelayVersion().Version,
+		"protocol":      1,
+		"user":          n.opts.User,
+		"pass":          n.opts.Password,
+		"auth_token":    n.opts.Token,
+		"headers":       true,
+		"no_responders": true,
+		"tls_required":  useTLS,
+	})
+	cmd := "CONNECT " + string(connect) + "\r\nPING\r\n"
+	if n.opts.JetStream {
+		n.inbox = "_INBOX.relayd." + strconv.FormatInt(time.Now().UnixNano(), 36)
+		cmd += "SUB " + n.inbox + ".* 1\r\n"
+	}
+	if _, err := n.conn.Write([]byte(cmd)); err != nil {
+		n.close()
+		return fmt.Errorf("failed to connect to NATS: %s", err)
+	}
+	if err := n.waitPong(); err != nil {
+		n.close()
+		return fmt.Errorf("failed to connect to NATS: %s", err)
+	}
+	return nil
+}
+
+// upgradeTLS - performs TLS handshake over the connection, NATS servers
+// send their INFO in plain text before it
+func (n *NATS) upgradeTLS() error {
+	conn, err := clientTLS(n.conn, n.opts.Address, n.opts.TLSConfig)
+	if err != nil {
+		return err
+	}
+	n.conn = conn
+	n.r = bufio.NewReader(conn)
+	return nil
+}
+
+// clientTLS - performs client TLS handshake over the connection, server
+// name defaults to address host
+func clientTLS(conn net.Conn, address string, tlsConfig *tls.Config) (net.Conn, error) {
+	cfg := &tls.Config{}
+	if tlsConfig != nil {
+		cfg = tlsConfig.Clone()
+	}
+	if cfg.ServerName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+		cfg.ServerName = host
+	}
+
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+func (n *NATS) publish(ctx context.Context, subject string, msg []byte) (string, error) {
+	setDeadline(ctx, n.conn)
+
+	if !n.opts.JetStream {
+		// server answers PING after processing preceding PUB
+		cmd := fmt.Sprintf("PUB %s %d\r\n%s\r\nPING\r\n", subject, len(msg), msg)
+		if _, err := n.conn.Write([]byte(cmd)); err != nil {
+			return "", err
+		}
+		if err := n.waitPong(); err != nil {
+			return "", err
+		}
+		return "flushed", nil
+	}
+
+	n.seq++
+	reply := n.inbox + "." + strconv.FormatUint(n.seq, 10)
+	cmd := fmt.Sprintf("PUB %s %s %d\r\n%s\r\n", subject, reply, len(msg), msg)
+	if _, err := n.conn.Write([]byte(cmd)); err != nil {
+		return "", err
+	}
+
+	for {
+		subj, headers, payload, err := n.readMsg()
+		if err != nil {
+			return "", err
+		}
+		// replies to earlier publishes that timed out are skipped
+		if subj != reply {
+			continue
+		}
+		if strings.HasPrefix(headers, "NATS/1.0 503") {
+			return "", fmt.Errorf("no JetStream stream is bound to subject '%s'", subject)
+		}
+
+		var ack struct {
+			Stream string `json:"stream"`
+			Seq    uint64 `json:"seq"`
+			Error  *struct {
+				Code        int    `json:"code"`
+				Description string `json:"description"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(payload, &ack); err != nil {
+			return "", fmt.Errorf("invalid JetStream acknowledgement: %s", err)
+		}
+		if ack.Error != nil {
+			return "", fmt.Errorf("JetStream error %d: %s", ack.Error.Code, ack.Error.Description)
+		}
+		return fmt.Sprintf("stream %s, sequence %d", ack.Stream, ack.Seq), nil
+	}
+}
+
+// waitPong - reads until server answers PING
+func (n *NATS) waitPong() error {
+	for {
+		line, err := n.readLine()
+		if err != nil {
+			return err
+		}
+		if done, err := n.control(line); done || err != nil {
+			return err
+		}
+	}
+}
+
+// readMsg - reads until a message arrives, returns its subject, headers
+// and payload
+func (n *NATS) readMsg() (subject, headers string, payload []byte, err error) {
+	for {
+		line, err := n.readLine()
+		if err != nil {
+			return "", "", nil, err
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "MSG", "HMSG":
+			// MSG <subject> <sid> [reply] <size>
+			// HMSG <subject> <sid> [reply] <header size> <total size>
+			headerSize := 0
+			total, err := strconv.Atoi(fields[len(fields)-1])
+			if err == nil && fields[0] == "HMSG" && len(fields) >= 5 {
+				headerSize, err = strconv.Atoi(fields[len(fields)-2])
+			}
+			if err != nil || len(fields) < 4 || headerSize > total {
+				return "", "", nil, fmt.Errorf("invalid NATS message: %q", line)
+			}
+			data := make([]byte, total+2)
+			if _, err := io.ReadFull(n.r, data); err != nil {
+				return "", "", nil, err
+			}
+			return fields[1], string(data[:headerSize]), data[headerSize:total], nil
+		}
+		if _, err := n.control(line); err != nil {
+			return "", "", nil, err
+		}
+	}
+}
+
+// control - handles control lines, reports PONG
+func (n *NATS) control(line string) (pong bool, err error) {
+	switch {
+	case line == "PONG":
+		return true, nil
+	case line == "PING":
+		_, err := n.conn.Write([]byte("PONG\r\n"))
+		return false, err
+	case strings.HasPrefix(line, "-ERR"):
+		return false, fmt.Errorf("NATS error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+	}
+	// +OK and INFO updates
+	return false, nil
+}
+
+func (n *NATS) readLine() (string, error) {
+	line, err := n.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// setDeadline - applies context deadline to connection
+func setDeadline(ctx context.Context, conn net.Conn) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Time{}
+	}
+	conn.SetDeadline(deadline)
+}
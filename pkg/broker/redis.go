@@ -0,0 +1,172 @@
+package broker
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RedisOpts - Redis streams publisher configuration
+type RedisOpts struct {
+	// Address - server host:port
+	Address string
+	// Username and Password - optional credentials, Username requires
+	// Redis 6 ACLs
+	Username, Password string
+	// DB - database number
+	DB int
+	// MaxLen - approximate maximum stream length, zero means no limit
+	MaxLen int64
+	// TLSConfig - connect over TLS with these settings, plain TCP is
+	// used when nil
+	TLSConfig *tls.Config
+}
+
+var _ Publisher = &Redis{}
+
+// Redis - minimal Redis client that adds messages to streams with XADD,
+// stream entries have a single data field with the message
+type Redis struct {
+	opts *RedisOpts
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// NewRedis - creates Redis publisher, connection is established on
+// first publish
+func NewRedis(opts *RedisOpts) *Redis {
+	return &Redis{opts: opts}
+}
+
+// Publish - adds message to stream, returns entry ID
+func (r *Redis) Publish(ctx context.Context, stream string, msg []byte) (string, error) {
+	if stream == "" {
+		return "", fmt.Errorf("stream is required")
+	}
+
+	args := []string{"XADD", stream}
+	if r.opts.MaxLen > 0 {
+		args = append(args, "MAXLEN", "~", strconv.FormatInt(r.opts.MaxLen, 10))
+	}
+	args = append(args, "*", "data", string(msg))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		if err := r.connect(ctx); err != nil {
+			return "", err
+		}
+	}
+	setDeadline(ctx, r.conn)
+	reply, err := r.do(args...)
+	if err != nil {
+		r.close()
+		return "", err
+	}
+	return "entry " + reply, nil
+}
+
+// Close - closes connection
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.close()
+}
+
+func (r *Redis) close() error {
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn, r.r = nil, nil
+	return err
+}
+
+func (r *Redis) connect(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", r.opts.Address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Redis: %s", err)
+	}
+	setDeadline(ctx, conn)
+	if r.opts.TLSConfig != nil {
+		tlsConn, err := clientTLS(conn, r.opts.Address, r.opts.TLSConfig)
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("Redis TLS handshake failed: %s", err)
+		}
+		conn = tlsConn
+	}
+	r.conn = conn
+	r.r = bufio.NewReader(conn)
+
+	if r.opts.Password != "" {
+		args := []string{"AUTH", r.opts.Password}
+		if r.opts.Username != "" {
+			args = []string{"AUTH", r.opts.Username, r.opts.Password}
+		}
+		if _, err := r.do(args...); err != nil {
+			r.close()
+			return fmt.Errorf("failed to authenticate to Redis: %s", err)
+		}
+	}
+	if r.opts.DB != 0 {
+		if _, err := r.do("SELECT", strconv.Itoa(r.opts.DB)); err != nil {
+			r.close()
+			return fmt.Errorf("failed to select Redis database: %s", err)
+		}
+	}
+	return nil
+}
+
+// do - sends command and reads its reply, errors and simple, integer and
+// bulk string replies are supported
+func (r *Redis) do(args ...string) (string, error) {
+	var b strings.Builder
+	b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		b.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+	if _, err := io.WriteString(r.conn, b.String()); err != nil {
+		return "", err
+	}
+
+	line, err := r.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", fmt.Errorf("empty Redis reply")
+	}
+
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", fmt.Errorf("redis error: %s", line[1:])
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("invalid Redis reply: %q", line)
+		}
+		if size < 0 {
+			return "", nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r.r, data); err != nil {
+			return "", err
+		}
+		return string(data[:size]), nil
+	}
+	return "", fmt.Errorf("unexpected Redis reply: %q", line)
+}
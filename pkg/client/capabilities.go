@@ -0,0 +1,179 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mailru/easyjson"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+	"github.com/webhookrelay/relay-go/version"
+)
+
+// serverInfo - server protocol version and capabilities received during
+// authentication
+type serverInfo struct {
+	mu           sync.Mutex
+	apiVersion   string
+	capabilities []string
+}
+
+// capabilities - features supported by this client, advertised to server
+func (c *DefaultClient) capabilities() []string {
+	caps := []string{types.CapabilityBinaryBodies, types.CapabilityUnsubscribe}
+	if c.opts.Compression || c.opts.GzipLogUpdates {
+		caps = append(caps, types.CapabilityCompression)
+	}
+	if c.opts.WebsocketLogUpdates {
+		caps = append(caps, types.CapabilityLogUpdates)
+	}
+	return caps
+}
+
+// authRequest - authentication request with client version and capabilities
+func (c *DefaultClient) authRequest() *types.ActionRequest {
+	v := version.GetWebhookRelayVersion()
+	return &types.ActionRequest{
+		Action:       "auth",
+		Key:          c.opts.AccessKey,
+		Secret:       c.opts.AccessSecret,
+		Version:      v.Version,
+		APIVersion:   v.APIVersion,
+		Capabilities: c.capabilities(),
+	}
+}
+
+// setServerInfo - stores server handshake and selects features. Servers
+// that don't support the handshake send no capabilities, in which case
+// features are detected when first used
+func (c *DefaultClient) setServerInfo(event *types.Event) {
+	c.server.mu.Lock()
+	c.server.apiVersion = event.APIVersion
+	c.server.capabilities = event.Capabilities
+	c.server.mu.Unlock()
+
+	if event.APIVersion == "" && event.Capabilities == nil {
+		c.logger.Debug("server did not advertise its capabilities, detecting them on first use")
+		atomic.StoreInt32(&c.wsLogs, wsLogsUnknown)
+		return
+	}
+
+	if major(event.APIVersion) != major(version.APIVersion) {
+		c.logger.Warnw("server protocol version differs from client version, consider upgrading relayd",
+			"server_api_version", event.APIVersion,
+			"client_api_version", version.APIVersion,
+		)
+	}
+	c.logger.Infow("server capabilities",
+		"api_version", event.APIVersion,
+		"capabilities", event.Capabilities,
+	)
+
+	if c.supports(types.CapabilityLogUpdates) {
+		atomic.StoreInt32(&c.wsLogs, wsLogsSupported)
+	} else {
+		atomic.StoreInt32(&c.wsLogs, wsLogsUnsupported)
+	}
+	if c.supports(types.CapabilityBatchLogUpdates) {
+		atomic.StoreInt32(&c.noBatchLogs, 0)
+	} else {
+		atomic.StoreInt32(&c.noBatchLogs, 1)
+	}
+	if c.opts.GzipLogUpdates && c.supports(types.CapabilityCompression) {
+		atomic.StoreInt32(&c.gzipLogs, 1)
+	} else {
+		atomic.StoreInt32(&c.gzipLogs, 0)
+	}
+}
+
+// supports - reports whether server advertised the capability
+func (c *DefaultClient) supports(capability string) bool {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	for _, cap := range c.server.capabilities {
+		if cap == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// ServerCapabilities - capabilities advertised by the server, empty
+// when server doesn't support the handshake
+func (c *DefaultClient) ServerCapabilities() []string {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	return append([]string(nil), c.server.capabilities...)
+}
+
+// Unsubscribe - stops forwarding webhooks from given buckets without
+// reconnecting, requires server support
+func (c *DefaultClient) Unsubscribe(buckets ...string) error {
+	if !c.supports(types.CapabilityUnsubscribe) {
+		return fmt.Errorf("server does not support unsubscribing")
+	}
+
+	c.filterMu.Lock()
+	c.filter.Buckets = remove(c.filter.Buckets, buckets)
+	if contains(buckets, c.filter.Bucket) {
+		c.filter.Bucket = ""
+	}
+	c.filterMu.Unlock()
+
+	bts, err := easyjson.Marshal(&types.ActionRequest{
+		Action:  "unsubscribe",
+		Buckets: buckets,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal unsubscribe request: %s", err)
+	}
+	return c.writeMessage(bts)
+}
+
+// handleUnknownEvent - ignores events this client doesn't understand,
+// requests that expect an acknowledgement are rejected as unsupported
+func (c *DefaultClient) handleUnknownEvent(event *types.Event) error {
+	if _, seen := c.unknownEvents.LoadOrStore(event.Type, true); !seen {
+		c.logger.Infow("ignoring unsupported event type, consider upgrading relayd",
+			"type", event.Type,
+		)
+	}
+	if event.RequestID == "" {
+		return nil
+	}
+
+	bts, err := easyjson.Marshal(&types.ActionRequest{
+		Action:    "ack",
+		RequestID: event.RequestID,
+		Status:    ackStatusUnsupported,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal ack request: %s", err)
+	}
+	return c.writeMessage(bts)
+}
+
+func major(v string) string {
+	return strings.SplitN(v, ".", 2)[0]
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(values, removed []string) []string {
+	var out []string
+	for _, v := range values {
+		if !contains(removed, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
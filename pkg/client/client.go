@@ -2,16 +2,24 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/hashicorp/go-cleanhttp"
 	"go.uber.org/zap"
 
 	"github.com/webhookrelay/relay-go/pkg/cond"
+	"github.com/webhookrelay/relay-go/pkg/dedup"
 	"github.com/webhookrelay/relay-go/pkg/forward"
 	"github.com/webhookrelay/relay-go/pkg/gopool"
 	"github.com/webhookrelay/relay-go/pkg/logger"
+	"github.com/webhookrelay/relay-go/pkg/logqueue"
+	"github.com/webhookrelay/relay-go/pkg/proxy"
+	"github.com/webhookrelay/relay-go/pkg/redact"
+	"github.com/webhookrelay/relay-go/pkg/retryablehttp"
 	"github.com/webhookrelay/relay-go/pkg/types"
 )
 
@@ -19,6 +27,7 @@ type WebhookRelayClient interface {
 	// start webhook relay
 	StartRelay(ctx context.Context, filter *Filter) error
 	RelayReady() <-chan bool
+	Health() Health
 }
 
 var _ WebhookRelayClient = &DefaultClient{}
@@ -41,26 +50,99 @@ type Opts struct {
 	AccessKey, AccessSecret string
 	// Optional way to turn off TLS certificate validation
 	InsecureSkipVerify bool
-	Forwarder          forward.Forwarder
-	Debug              bool
-	Logger             *zap.SugaredLogger
+	// Proxy - optional proxy for the websocket connection and log
+	// updates, defaults to HTTPS_PROXY and NO_PROXY environment variables
+	Proxy proxy.Func
+	// TLSConfig - optional TLS settings for Webhook Relay server
+	// connections such as a dedicated CA bundle or minimum version
+	TLSConfig *tls.Config
+	Forwarder forward.Forwarder
+	Debug     bool
+	Logger    *zap.SugaredLogger
 	// Websocket server address, defaults to
 	// wss://my.webhookrelay.com/
 	ServerAddress string
+	// Dialer - optional websocket dialer, defaults to NewDialer with
+	// client Proxy and TLS settings
+	Dialer *websocket.Dialer
+	// Header - optional websocket handshake headers, User-Agent defaults
+	// to relay-go/<version>
+	Header http.Header
+	// Dedup - optional cache of delivered events, redelivered webhooks
+	// are not forwarded again and their original result is sent instead
+	Dedup *dedup.Cache
+	// Redaction - optional policy applied to webhooks before they
+	// are written to debug logs
+	Redaction *redact.Policy
+	// Compression - negotiate permessage-deflate websocket compression,
+	// ignored when Dialer is set
+	Compression bool
+	// CompressionLevel - flate compression level for outgoing websocket
+	// messages, zero uses the default (best speed)
+	CompressionLevel int
+	// GzipLogUpdates - gzip log update bodies when server advertises
+	// compression support, client falls back to plain bodies if server
+	// rejects them
+	GzipLogUpdates bool
+	// LogQueue - optional queue of delivery results waiting to be sent
+	// to Webhook Relay, defaults to an in-memory queue
+	LogQueue *logqueue.Queue
+	// LogRetries - attempts to send a log update before it is put back
+	// to the queue for a later retry
+	LogRetries int
+	// WebsocketLogUpdates - send delivery results over the websocket
+	// connection, falls back to HTTP if server doesn't support it
+	WebsocketLogUpdates bool
 }
 
+// DefaultHTTPTimeout - timeout for Webhook Relay API requests when
+// client creates its own HTTP client
+var DefaultHTTPTimeout = 30 * time.Second
+
+// DefaultLogRetries - default number of log update retries
+var DefaultLogRetries = 4
+
 // DefaultClient - default client that connects to webhookrelay service via gRPC protocol
 type DefaultClient struct {
 	httpClient   *http.Client
 	forwarder    forward.Forwarder
+	dedup        *dedup.Cache
+	redaction    *redact.Policy
+	dialer       *websocket.Dialer
+	header       http.Header
 	wsConn       *websocket.Conn
+	wsMu         sync.Mutex
 	wsHealthPing chan *types.Event
 	opts         *Opts
 	filter       *Filter
 	readyCond    *cond.Cond
 	goPool       *gopool.Pool
 	readyMu      *sync.Mutex
-	logger       *zap.SugaredLogger
+	health       *healthState
+	// authErrCh - receives an error when server rejects credentials
+	authErrCh chan error
+	// gzipLogs - 1 while log updates are sent gzipped
+	gzipLogs int32
+	// logs - queue of delivery results, sent by logClient
+	logs      *logqueue.Queue
+	logClient *retryablehttp.Client
+	// noBatchLogs - 1 once server rejected batched log updates
+	noBatchLogs int32
+	// wsLogs - websocket log update support state
+	wsLogs int32
+	// acks - acknowledgement channels keyed by request ID
+	acksMu    sync.Mutex
+	requestID uint64
+	acks      map[string]chan *types.Event
+	// wsLogsProbeAfter - websocket log updates are not probed again
+	// before this time, guarded by acksMu
+	wsLogsProbeAfter time.Time
+	// server - handshake received during authentication
+	server serverInfo
+	// unknownEvents - event types that were already reported
+	unknownEvents sync.Map
+	filterMu      sync.Mutex
+	logger        *zap.SugaredLogger
 }
 
 // NewDefaultClient - create new default client with given options
@@ -70,24 +152,83 @@ func NewDefaultClient(opts *Opts) *DefaultClient {
 	}
 
 	if opts.HTTPClient == nil {
-		opts.HTTPClient = http.DefaultClient
+		tr := cleanhttp.DefaultPooledTransport()
+		if opts.Proxy != nil {
+			tr.Proxy = opts.Proxy
+		}
+		tr.TLSClientConfig = upstreamTLSConfig(opts)
+		opts.HTTPClient = &http.Client{Transport: tr, Timeout: DefaultHTTPTimeout}
+	}
+
+	if opts.LogQueue == nil {
+		// in-memory queue cannot fail
+		opts.LogQueue, _ = logqueue.New(&logqueue.Opts{})
+	}
+	if opts.LogRetries == 0 {
+		opts.LogRetries = DefaultLogRetries
+	}
+	logClient := retryablehttp.NewClient(opts.Logger)
+	logClient.HTTPClient = opts.HTTPClient
+	logClient.RetryMax = opts.LogRetries
+
+	if opts.Dialer == nil {
+		opts.Dialer = NewDialer(&DialerOpts{
+			EnableCompression: opts.Compression,
+			Proxy:             opts.Proxy,
+			TLSConfig:         upstreamTLSConfig(opts),
+		})
+	}
+
+	header := http.Header{}
+	for k, v := range opts.Header {
+		header[k] = v
+	}
+	if header.Get("User-Agent") == "" {
+		header.Set("User-Agent", UserAgent())
 	}
 
 	return &DefaultClient{
 		opts:         opts,
 		httpClient:   opts.HTTPClient,
+		dialer:       opts.Dialer,
+		header:       header,
 		logger:       opts.Logger,
 		forwarder:    opts.Forwarder,
+		dedup:        opts.Dedup,
+		redaction:    opts.Redaction,
 		goPool:       gopool.NewPool(workers, queue, 1),
 		readyCond:    &cond.Cond{},
 		readyMu:      &sync.Mutex{},
 		wsHealthPing: make(chan *types.Event),
+		health:       &healthState{},
+		authErrCh:    make(chan error, 1),
+		logs:         opts.LogQueue,
+		logClient:    logClient,
+		acks:         make(map[string]chan *types.Event),
 	}
 }
 
+// upstreamTLSConfig - TLS config for Webhook Relay server connections
+func upstreamTLSConfig(opts *Opts) *tls.Config {
+	cfg := &tls.Config{}
+	if opts.TLSConfig != nil {
+		cfg = opts.TLSConfig.Clone()
+	}
+	if opts.InsecureSkipVerify {
+		cfg.InsecureSkipVerify = true
+	}
+	return cfg
+}
+
 // StartRelay - starts relay agent
 func (c *DefaultClient) StartRelay(ctx context.Context, filter *Filter) error {
 	c.filter = filter
+
+	logsCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go c.logs.Run(logsCtx, c.sendLogs)
+	go c.reportLogLag(logsCtx)
+
 	return c.startWebSocketRelay(ctx)
 }
 
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/webhookrelay/relay-go/pkg/proxy"
+	"github.com/webhookrelay/relay-go/version"
+)
+
+// default websocket dialer settings
+var (
+	DefaultHandshakeTimeout = 45 * time.Second
+	DefaultDialTimeout      = 30 * time.Second
+	DefaultKeepAlive        = 30 * time.Second
+)
+
+// DialerOpts - websocket dialer configuration
+type DialerOpts struct {
+	// HandshakeTimeout - duration for the websocket handshake to complete
+	HandshakeTimeout time.Duration
+	// EnableCompression - attempt to negotiate per message compression
+	EnableCompression bool
+	// ReadBufferSize and WriteBufferSize - I/O buffer sizes, zero
+	// values use gorilla/websocket defaults
+	ReadBufferSize, WriteBufferSize int
+
+	// DialTimeout, KeepAlive and LocalAddr configure the underlying
+	// TCP connection
+	DialTimeout time.Duration
+	KeepAlive   time.Duration
+	LocalAddr   net.Addr
+
+	// Proxy - optional proxy, defaults to environment variables
+	Proxy proxy.Func
+	// TLSConfig - optional TLS settings
+	TLSConfig *tls.Config
+}
+
+// NewDialer - creates websocket dialer. Each dialer is independent so
+// clients with different settings can coexist in one process
+func NewDialer(opts *DialerOpts) *websocket.Dialer {
+	netDialer := &net.Dialer{
+		Timeout:   opts.DialTimeout,
+		KeepAlive: opts.KeepAlive,
+		LocalAddr: opts.LocalAddr,
+	}
+	if netDialer.Timeout == 0 {
+		netDialer.Timeout = DefaultDialTimeout
+	}
+	if netDialer.KeepAlive == 0 {
+		netDialer.KeepAlive = DefaultKeepAlive
+	}
+
+	d := &websocket.Dialer{
+		HandshakeTimeout:  opts.HandshakeTimeout,
+		EnableCompression: opts.EnableCompression,
+		ReadBufferSize:    opts.ReadBufferSize,
+		WriteBufferSize:   opts.WriteBufferSize,
+		NetDialContext:    netDialer.DialContext,
+		Proxy:             http.ProxyFromEnvironment,
+	}
+	if d.HandshakeTimeout == 0 {
+		d.HandshakeTimeout = DefaultHandshakeTimeout
+	}
+	if opts.TLSConfig != nil {
+		d.TLSClientConfig = opts.TLSConfig.Clone()
+	}
+	if opts.Proxy != nil {
+		d.Proxy, d.NetDialContext = proxy.ForWebsocket(opts.Proxy, nil, netDialer.DialContext)
+	}
+
+	return d
+}
+
+// UserAgent - user agent sent to Webhook Relay server
+func UserAgent() string {
+	v := version.GetWebhookRelayVersion()
+	return fmt.Sprintf("%s/%s (%s/%s)", v.Name, v.Version, runtime.GOOS, runtime.GOARCH)
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestNewDialer(t *testing.T) {
+	d := NewDialer(&DialerOpts{
+		HandshakeTimeout:  5 * time.Second,
+		EnableCompression: true,
+		ReadBufferSize:    8192,
+		WriteBufferSize:   4096,
+	})
+
+	if d.HandshakeTimeout != 5*time.Second {
+		t.Errorf("unexpected handshake timeout: %s", d.HandshakeTimeout)
+	}
+	if !d.EnableCompression {
+		t.Errorf("expected compression to be enabled")
+	}
+	if d.ReadBufferSize != 8192 || d.WriteBufferSize != 4096 {
+		t.Errorf("unexpected buffer sizes: %d/%d", d.ReadBufferSize, d.WriteBufferSize)
+	}
+	if d == websocket.DefaultDialer {
+		t.Errorf("expected a dedicated dialer")
+	}
+}
+
+func TestInsecureClientKeepsDefaultDialer(t *testing.T) {
+	c := NewDefaultClient(&Opts{InsecureSkipVerify: true})
+	if !c.dialer.TLSClientConfig.InsecureSkipVerify {
+		t.Errorf("expected client dialer to skip TLS verification")
+	}
+
+	other := NewDefaultClient(&Opts{})
+	if other.dialer.TLSClientConfig.InsecureSkipVerify {
+		t.Errorf("expected other client dialer to verify TLS")
+	}
+
+	if websocket.DefaultDialer.TLSClientConfig != nil {
+		t.Errorf("websocket.DefaultDialer should not be modified")
+	}
+}
+
+func TestDialWebSocketHeaders(t *testing.T) {
+	userAgent := make(chan string, 1)
+	custom := make(chan string, 1)
+
+	upgrader := websocket.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent <- r.Header.Get("User-Agent")
+		custom <- r.Header.Get("X-Custom")
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}))
+	defer ts.Close()
+
+	c := NewDefaultClient(&Opts{
+		ServerAddress: ts.URL,
+		Header:        http.Header{"X-Custom": []string{"foo"}},
+	})
+
+	conn, err := c.dialWebSocket(context.Background())
+	if err != nil {
+		t.Fatalf("failed to dial: %s", err)
+	}
+	conn.Close()
+
+	if ua := <-userAgent; !strings.HasPrefix(ua, "relay-go/") {
+		t.Errorf("unexpected user agent: %s", ua)
+	}
+	if v := <-custom; v != "foo" {
+		t.Errorf("unexpected custom header: %s", v)
+	}
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// Health - connection health snapshot
+type Health struct {
+	// Connected - websocket connection is established
+	Connected bool
+	// Authenticated - server accepted client credentials
+	Authenticated bool
+	// LastPing - last time server ping was received
+	LastPing time.Time
+	// Reconnects - number of times connection was re-established
+	Reconnects int
+	// LastError - last connection error, if any
+	LastError string
+	// ServerAPIVersion and ServerCapabilities - protocol version and
+	// features advertised by server, empty for servers that don't
+	// support the handshake
+	ServerAPIVersion   string
+	ServerCapabilities []string
+
+	// LogUpdatesPending - delivery results not yet accepted by server
+	LogUpdatesPending int
+	// LogUpdatesSent and LogUpdatesFailed - sent delivery results and
+	// failed attempts to send them
+	LogUpdatesSent, LogUpdatesFailed uint64
+	// LogUpdateLag - age of the oldest pending delivery result
+	LogUpdateLag time.Duration
+}
+
+type healthState struct {
+	mu     sync.Mutex
+	health Health
+}
+
+func (s *healthState) update(fn func(h *Health)) {
+	s.mu.Lock()
+	fn(&s.health)
+	s.mu.Unlock()
+}
+
+func (s *healthState) get() Health {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.health
+}
+
+// Health - returns current connection health
+func (c *DefaultClient) Health() Health {
+	h := c.health.get()
+	stats := c.logs.Stats()
+	h.LogUpdatesPending = stats.Pending
+	h.LogUpdatesSent = stats.Sent
+	h.LogUpdatesFailed = stats.Failed
+	h.LogUpdateLag = stats.Lag
+
+	c.server.mu.Lock()
+	h.ServerAPIVersion = c.server.apiVersion
+	h.ServerCapabilities = append([]string(nil), c.server.capabilities...)
+	c.server.mu.Unlock()
+	return h
+}
@@ -0,0 +1,222 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/mailru/easyjson"
+
+	"github.com/webhookrelay/relay-go/pkg/retryablehttp"
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// minGzipSize - log updates smaller than this are not worth compressing
+const minGzipSize = 1024
+
+var errBatchUnsupported = errors.New("server does not support batched log updates")
+
+// logLagReportPeriod - how often pending log updates are reported
+var logLagReportPeriod = time.Minute
+
+// reportLogLag - periodically warns about delivery results that are
+// waiting to be sent
+func (c *DefaultClient) reportLogLag(ctx context.Context) {
+	ticker := time.NewTicker(logLagReportPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats := c.logs.Stats()
+			if stats.Pending == 0 || stats.Lag < logLagReportPeriod {
+				continue
+			}
+			c.logger.Warnw("delivery results are waiting to be sent to Webhook Relay",
+				"pending", stats.Pending,
+				"lag", stats.Lag.String(),
+				"failed_attempts", stats.Failed,
+				"dropped", stats.Dropped,
+			)
+		}
+	}
+}
+
+// sendResponse - queues delivery result, it is sent to Webhook Relay
+// asynchronously. Forwarders that don't deliver webhooks, such as
+// printers, return no result and nothing is sent
+func (c *DefaultClient) sendResponse(webhookResponse *types.LogUpdateRequest) error {
+	if webhookResponse == nil {
+		return nil
+	}
+	return c.logs.Push(webhookResponse)
+}
+
+// sendLogs - sends queued delivery results over websocket or HTTP,
+// batching them when server supports it. Returns updates that could
+// not be sent and updates that were rejected by the server
+func (c *DefaultClient) sendLogs(updates []*types.LogUpdateRequest) (failed, rejected []*types.LogUpdateRequest) {
+	if c.useWSLogs() {
+		err := c.sendLogsWS(updates)
+		switch {
+		case err == nil:
+			return nil, nil
+		case err == errWSLogsUnsupported:
+			c.logger.Info("server does not support log updates over websocket, using HTTP")
+			atomic.StoreInt32(&c.wsLogs, wsLogsUnsupported)
+		case err == errWSLogsProbeTimeout:
+			c.logger.Infow("server did not acknowledge log updates over websocket, using HTTP",
+				"retry_in", logProbeBackoff.String(),
+			)
+		default:
+			c.logger.Warnw("failed to send log updates over websocket, using HTTP",
+				"count", len(updates),
+				"error", err,
+			)
+		}
+	}
+
+	if len(updates) > 1 && atomic.LoadInt32(&c.noBatchLogs) == 0 {
+		status, err := c.sendLogBatch(updates)
+		switch {
+		case err == nil:
+			return nil, nil
+		case err == errBatchUnsupported:
+			c.logger.Info("server does not support batched log updates, sending them one by one")
+			atomic.StoreInt32(&c.noBatchLogs, 1)
+		case permanentStatus(status):
+			// sending them one by one to find out which ones were rejected
+			c.logger.Warnw("batch of log updates was rejected, sending them one by one",
+				"count", len(updates),
+				"error", err,
+			)
+		default:
+			c.logger.Warnw("failed to send log updates, will retry",
+				"count", len(updates),
+				"error", err,
+			)
+			return updates, nil
+		}
+	}
+
+	for _, update := range updates {
+		status, err := c.sendLog(update)
+		switch {
+		case err == nil:
+		case permanentStatus(status):
+			c.logger.Warnw("log update was rejected, it will not be retried",
+				"id", update.ID,
+				"error", err,
+			)
+			rejected = append(rejected, update)
+		default:
+			c.logger.Warnw("failed to send log update, will retry",
+				"id", update.ID,
+				"error", err,
+			)
+			failed = append(failed, update)
+		}
+	}
+	return failed, rejected
+}
+
+// permanentStatus - whether server rejected the request and sending it
+// again won't help. Timeouts and rate limits are retried
+func permanentStatus(status int) bool {
+	if status == http.StatusRequestTimeout || status == http.StatusTooManyRequests {
+		return false
+	}
+	return status >= 400 && status < 500
+}
+
+func (c *DefaultClient) sendLog(update *types.LogUpdateRequest) (int, error) {
+	bts, err := easyjson.Marshal(update)
+	if err != nil {
+		return 0, err
+	}
+	return c.uploadLogs(http.MethodPut, "/v1/logs/"+update.ID, bts)
+}
+
+func (c *DefaultClient) sendLogBatch(updates []*types.LogUpdateRequest) (int, error) {
+	bts, err := easyjson.Marshal(&types.LogUpdateBatchRequest{Updates: updates})
+	if err != nil {
+		return 0, err
+	}
+	status, err := c.uploadLogs(http.MethodPost, "/v1/logs/batch", bts)
+	switch status {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return status, errBatchUnsupported
+	}
+	return status, err
+}
+
+// uploadLogs - sends log update request, gzipped when enabled and body
+// is large enough. Returns response status code
+func (c *DefaultClient) uploadLogs(method, path string, bts []byte) (int, error) {
+	if len(bts) >= minGzipSize && atomic.LoadInt32(&c.gzipLogs) == 1 {
+		status, err := c.doLogRequest(method, path, bts, true)
+		if status != http.StatusUnsupportedMediaType && status != http.StatusBadRequest {
+			return status, err
+		}
+		// server doesn't accept compressed bodies, sending
+		// this and all further updates uncompressed
+		c.logger.Info("server does not accept gzipped log updates, disabling compression")
+		atomic.StoreInt32(&c.gzipLogs, 0)
+	}
+
+	return c.doLogRequest(method, path, bts, false)
+}
+
+func (c *DefaultClient) doLogRequest(method, path string, bts []byte, compress bool) (int, error) {
+	if compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, err := zw.Write(bts)
+		if err == nil {
+			err = zw.Close()
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to compress log update: %s", err)
+		}
+		bts = buf.Bytes()
+	}
+
+	req, err := retryablehttp.NewRequest(method, c.opts.ServerAddress+path, bts)
+	if err != nil {
+		return 0, err
+	}
+
+	req.SetBasicAuth(c.opts.AccessKey, c.opts.AccessSecret)
+	req.Header.Set("User-Agent", c.header.Get("User-Agent"))
+	req.Header.Set("Content-Type", "application/json")
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := c.logClient.Do(req)
+	if err != nil {
+		if resp != nil {
+			return resp.StatusCode, err
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return resp.StatusCode, fmt.Errorf("unexpected status from Webhook Relay: %d", resp.StatusCode)
+		}
+
+		return resp.StatusCode, fmt.Errorf("unexpected status from Webhook Relay: %d (%s)", resp.StatusCode, string(body))
+	}
+
+	return resp.StatusCode, nil
+}
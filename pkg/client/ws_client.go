@@ -1,12 +1,8 @@
 package client
 
 import (
-	"bytes"
 	"context"
-	"crypto/tls"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"strings"
 	"time"
 
@@ -20,10 +16,12 @@ var websocketHealthPingTimeout = time.Second * 53
 
 func (c *DefaultClient) dialWebSocket(ctx context.Context) (*websocket.Conn, error) {
 
+	c.wsMu.Lock()
 	if c.wsConn != nil {
 		// closing any existing connection
 		c.wsConn.Close()
 	}
+	c.wsMu.Unlock()
 
 	webSocketAddress := c.opts.ServerAddress + "/v1/socket"
 
@@ -34,13 +32,7 @@ func (c *DefaultClient) dialWebSocket(ctx context.Context) (*websocket.Conn, err
 		webSocketAddress = strings.Replace(webSocketAddress, "http", "ws", 1)
 	}
 
-	if c.opts.InsecureSkipVerify {
-		websocket.DefaultDialer.TLSClientConfig = &tls.Config{
-			InsecureSkipVerify: true,
-		}
-	}
-
-	conn, _, err := websocket.DefaultDialer.Dial(webSocketAddress, nil)
+	conn, resp, err := c.dialer.DialContext(ctx, webSocketAddress, c.header)
 	if err != nil {
 		c.logger.Errorw("websocket connection to Webhook Relay failed",
 			"error", err,
@@ -49,16 +41,44 @@ func (c *DefaultClient) dialWebSocket(ctx context.Context) (*websocket.Conn, err
 		return nil, fmt.Errorf("websocket dial to '%s' failed, error: %s", webSocketAddress, err)
 	}
 
+	if c.dialer.EnableCompression {
+		// servers that don't support compression simply don't confirm
+		// the extension and messages are sent uncompressed
+		if strings.Contains(resp.Header.Get("Sec-Websocket-Extensions"), "permessage-deflate") {
+			c.logger.Debug("websocket compression negotiated")
+		} else {
+			c.logger.Debug("server does not support websocket compression")
+		}
+		if c.opts.CompressionLevel != 0 {
+			err = conn.SetCompressionLevel(c.opts.CompressionLevel)
+			if err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("invalid compression level: %s", err)
+			}
+		}
+	}
+
 	return conn, nil
 }
 
 func (c *DefaultClient) startWebSocketRelay(ctx context.Context) error {
 
 	wsHealthTimer := time.NewTimer(websocketHealthPingTimeout)
+	defer c.health.update(func(h *Health) {
+		h.Connected = false
+		h.Authenticated = false
+	})
+
+	connected := false
 
 RECONNECT:
+	c.health.update(func(h *Health) {
+		h.Connected = false
+		h.Authenticated = false
+	})
 	conn, err := c.dialWebSocket(ctx)
 	if err != nil {
+		c.health.update(func(h *Health) { h.LastError = err.Error() })
 		// retrying connection forever
 		select {
 		case <-ctx.Done():
@@ -72,8 +92,19 @@ RECONNECT:
 		}
 	}
 
+	c.wsMu.Lock()
 	c.wsConn = conn
-	defer c.wsConn.Close()
+	c.wsMu.Unlock()
+	defer conn.Close()
+
+	reconnected := connected
+	connected = true
+	c.health.update(func(h *Health) {
+		h.Connected = true
+		if reconnected {
+			h.Reconnects++
+		}
+	})
 
 	c.logger.Info("using websocket based transport...")
 
@@ -94,7 +125,7 @@ RECONNECT:
 				return
 			}
 			go func(msg []byte) {
-				err = c.handleWSMessage(msg)
+				err := c.handleWSMessage(msg)
 				if err != nil {
 					c.logger.Errorw("failed to process ws message",
 						"error", err,
@@ -107,16 +138,12 @@ RECONNECT:
 	// send authentication message
 	c.logger.Infof("authenticating to '%s'...", c.opts.ServerAddress)
 
-	bts, err := easyjson.Marshal(&types.ActionRequest{
-		Action: "auth",
-		Key:    c.opts.AccessKey,
-		Secret: c.opts.AccessSecret,
-	})
+	bts, err := easyjson.Marshal(c.authRequest())
 	if err != nil {
 		return fmt.Errorf("failed to marshal auth request: %s", err)
 	}
 
-	err = conn.WriteMessage(websocket.TextMessage, bts)
+	err = c.writeMessage(bts)
 	if err != nil {
 		c.logger.Errorw("failed to send authentication message",
 			"error", err,
@@ -128,9 +155,13 @@ RECONNECT:
 		select {
 		case <-ctx.Done():
 			return nil
+		case err := <-c.authErrCh:
+			c.health.update(func(h *Health) { h.LastError = err.Error() })
+			return err
 		case err, ok := <-readErrCh:
 			if ok {
 				if err != nil {
+					c.health.update(func(h *Health) { h.LastError = err.Error() })
 					c.logger.Warnf("websocket read failure: %s", err)
 					goto RECONNECT
 				}
@@ -146,10 +177,22 @@ RECONNECT:
 			}
 			// reseting the timer
 			wsHealthTimer.Reset(websocketHealthPingTimeout)
+			c.health.update(func(h *Health) { h.LastPing = time.Now() })
 		}
 	}
 }
 
+// writeMessage - sends message over current websocket connection,
+// gorilla/websocket supports only one concurrent writer
+func (c *DefaultClient) writeMessage(bts []byte) error {
+	c.wsMu.Lock()
+	defer c.wsMu.Unlock()
+	if c.wsConn == nil {
+		return fmt.Errorf("websocket connection is not established")
+	}
+	return c.wsConn.WriteMessage(websocket.TextMessage, bts)
+}
+
 func (c *DefaultClient) handleWSMessage(msg []byte) error {
 
 	var event types.Event
@@ -162,11 +205,16 @@ func (c *DefaultClient) handleWSMessage(msg []byte) error {
 	case "status":
 		switch event.Status {
 		case "authenticated":
-			buckets := c.filter.Buckets
+			c.setServerInfo(&event)
+
+			c.filterMu.Lock()
+			buckets := append([]string(nil), c.filter.Buckets...)
 			if c.filter.Bucket != "" {
 				buckets = append(buckets, c.filter.Bucket)
 			}
+			c.filterMu.Unlock()
 			// notifying readiness
+			c.health.update(func(h *Health) { h.Authenticated = true })
 			c.readyCond.Notify()
 
 			// subscribing to buckets
@@ -178,7 +226,7 @@ func (c *DefaultClient) handleWSMessage(msg []byte) error {
 				return fmt.Errorf("failed to marshal subscribe request: %s", err)
 			}
 			c.logger.Infof("subscribing to buckets: %s", buckets)
-			err = c.wsConn.WriteMessage(websocket.TextMessage, bts)
+			err = c.writeMessage(bts)
 			if err != nil {
 				c.logger.Errorw("failed to send subscribe message",
 					"error", err,
@@ -186,8 +234,13 @@ func (c *DefaultClient) handleWSMessage(msg []byte) error {
 			}
 			return err
 		case "unauthorized":
-			c.logger.Fatalf("authentication failed, check your credentials")
-			return fmt.Errorf("authentication failed")
+			c.logger.Errorf("authentication failed, check your credentials")
+			err := fmt.Errorf("authentication failed")
+			select {
+			case c.authErrCh <- err:
+			default:
+			}
+			return err
 		case "ping":
 			bts, err := easyjson.Marshal(&types.ActionRequest{
 				Action: "pong",
@@ -196,7 +249,7 @@ func (c *DefaultClient) handleWSMessage(msg []byte) error {
 			if err != nil {
 				return fmt.Errorf("failed to marshal pong request: %s", err)
 			}
-			err = c.wsConn.WriteMessage(websocket.TextMessage, bts)
+			err = c.writeMessage(bts)
 			if err != nil {
 				c.logger.Errorw("failed to send a message",
 					"error", err,
@@ -205,47 +258,67 @@ func (c *DefaultClient) handleWSMessage(msg []byte) error {
 			return err
 		}
 
+	case "ack":
+		c.handleAck(&event)
 	case "webhook":
-		resp, err := c.forwarder.Forward(event)
-		if err != nil {
-			return err
+		if event.Meta.ReceivedAt.IsZero() {
+			event.Meta.ReceivedAt = time.Now().UTC()
 		}
-
-		return c.sendResponse(resp)
+		return c.handleWebhook(event)
 	default:
-		c.logger.Warnf("unknown event type: %s", event, true)
+		return c.handleUnknownEvent(&event)
 	}
 	return nil
 }
 
-func (c *DefaultClient) sendResponse(webhookResponse *types.LogUpdateRequest) error {
-
-	bts, err := easyjson.Marshal(webhookResponse)
-	if err != nil {
-		return err
+func (c *DefaultClient) handleWebhook(event types.Event) error {
+	if c.opts.Debug {
+		redacted := c.redaction.Event(event)
+		c.logger.Debugw("webhook received",
+			"id", redacted.Meta.ID,
+			"bucket", redacted.Meta.BucketName,
+			"destination", redacted.Meta.OutputDestination,
+			"method", redacted.Method,
+			"query", redacted.RawQuery,
+			"headers", redacted.Headers,
+			"body", redacted.Body,
+		)
 	}
 
-	req, err := http.NewRequest(http.MethodPut, c.opts.ServerAddress+"/v1/logs/"+webhookResponse.ID, bytes.NewBuffer(bts))
-	if err != nil {
-		return err
+	if c.dedup == nil || event.Meta.ID == "" {
+		resp, err := c.forwarder.Forward(event)
+		if err != nil {
+			return err
+		}
+		return c.sendResponse(resp)
 	}
 
-	req.SetBasicAuth(c.opts.AccessKey, c.opts.AccessSecret)
+	cached, first := c.dedup.Start(event.Meta.ID)
+	if !first {
+		if cached == nil {
+			c.logger.Infow("skipping duplicate webhook, it is still being delivered",
+				"id", event.Meta.ID,
+			)
+			return nil
+		}
+		c.logger.Infow("skipping already delivered webhook, resending delivery result",
+			"id", event.Meta.ID,
+		)
+		return c.sendResponse(cached)
+	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.forwarder.Forward(event)
 	if err != nil {
+		c.dedup.Abort(event.Meta.ID)
 		return err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("unexpected status from Webhook Relay: %d", resp.StatusCode)
-		}
-
-		return fmt.Errorf("unexpected status from Webhook Relay: %d (%s)", resp.StatusCode, string(body))
+	err = c.dedup.Finish(event.Meta.ID, resp)
+	if err != nil {
+		c.logger.Warnw("failed to persist delivery result",
+			"id", event.Meta.ID,
+			"error", err,
+		)
 	}
 
-	return nil
+	return c.sendResponse(resp)
 }
@@ -0,0 +1,685 @@
+package client
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mailru/easyjson"
+
+	"github.com/webhookrelay/relay-go/pkg/dedup"
+	"github.com/webhookrelay/relay-go/pkg/types"
+	"github.com/webhookrelay/relay-go/version"
+)
+
+// fakeServer - minimal Webhook Relay websocket server
+type fakeServer struct {
+	*httptest.Server
+	// secret - accepted access secret
+	secret string
+	// actions - received action requests
+	actions chan *types.ActionRequest
+	// conns - accepted connections
+	conns chan *websocket.Conn
+	// logs - received log updates
+	logs chan *types.LogUpdateRequest
+	// encodings - Content-Encoding of received log updates
+	encodings chan string
+	// rejectGzip - status returned for gzipped log updates, 0 accepts them
+	rejectGzip int
+	// compression - negotiate permessage-deflate
+	compression bool
+	// extensions - negotiated websocket extensions
+	extensions chan string
+	// batchLogs - accept batched log updates
+	batchLogs bool
+	// logFailures - number of log update requests to fail with
+	// logFailureStatus, 503 by default
+	logFailures      int32
+	logFailureStatus int
+	// wsLogs - accept log updates over websocket
+	wsLogs bool
+	// apiVersion and capabilities - handshake sent to authenticated
+	// clients, none are sent when both are empty
+	apiVersion   string
+	capabilities []string
+	// writeMu - serializes writes to server connections
+	writeMu sync.Mutex
+}
+
+func (s *fakeServer) write(conn *websocket.Conn, bts []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return conn.WriteMessage(websocket.TextMessage, bts)
+}
+
+func newFakeServer(t *testing.T, secret string) *fakeServer {
+	s := &fakeServer{
+		secret:     secret,
+		actions:    make(chan *types.ActionRequest, 100),
+		conns:      make(chan *websocket.Conn, 10),
+		logs:       make(chan *types.LogUpdateRequest, 100),
+		encodings:  make(chan string, 100),
+		extensions: make(chan string, 10),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v1/logs/") {
+			encoding := r.Header.Get("Content-Encoding")
+			s.encodings <- encoding
+			body := io.Reader(r.Body)
+			if encoding == "gzip" {
+				if s.rejectGzip != 0 {
+					w.WriteHeader(s.rejectGzip)
+					return
+				}
+				zr, err := gzip.NewReader(r.Body)
+				if err != nil {
+					t.Errorf("invalid gzip body: %s", err)
+					return
+				}
+				body = zr
+			}
+			if atomic.AddInt32(&s.logFailures, -1) >= 0 {
+				if s.logFailureStatus != 0 {
+					w.WriteHeader(s.logFailureStatus)
+					return
+				}
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			if r.URL.Path == "/v1/logs/batch" {
+				if !s.batchLogs {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				var batch types.LogUpdateBatchRequest
+				bts, _ := ioutil.ReadAll(body)
+				if err := easyjson.Unmarshal(bts, &batch); err != nil {
+					t.Errorf("failed to unmarshal log update batch: %s", err)
+				}
+				for _, update := range batch.Updates {
+					s.logs <- update
+				}
+				return
+			}
+			var update types.LogUpdateRequest
+			bts, _ := ioutil.ReadAll(body)
+			if err := easyjson.Unmarshal(bts, &update); err != nil {
+				t.Errorf("failed to unmarshal log update: %s", err)
+			}
+			update.ID = strings.TrimPrefix(r.URL.Path, "/v1/logs/")
+			s.logs <- &update
+			return
+		}
+		upgrader := websocket.Upgrader{EnableCompression: s.compression}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %s", err)
+			return
+		}
+		s.extensions <- r.Header.Get("Sec-Websocket-Extensions")
+		s.conns <- conn
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var req types.ActionRequest
+			if err := easyjson.Unmarshal(msg, &req); err != nil {
+				t.Errorf("failed to unmarshal action: %s", err)
+				return
+			}
+			s.actions <- &req
+			if req.Action == "log_update" && s.wsLogs {
+				for _, update := range req.LogUpdates {
+					s.logs <- update
+				}
+				bts, _ := easyjson.Marshal(&types.Event{Type: "ack", Status: "ok", RequestID: req.RequestID})
+				s.write(conn, bts)
+			}
+			if req.Action == "auth" {
+				status := "authenticated"
+				if req.Secret != s.secret {
+					status = "unauthorized"
+				}
+				bts, _ := easyjson.Marshal(&types.Event{
+					Type:         "status",
+					Status:       status,
+					APIVersion:   s.apiVersion,
+					Capabilities: s.capabilities,
+				})
+				s.write(conn, bts)
+			}
+		}
+	}))
+	return s
+}
+
+// send - sends event to the client over given connection
+func (s *fakeServer) send(t *testing.T, conn *websocket.Conn, event *types.Event) {
+	t.Helper()
+	bts, err := easyjson.Marshal(event)
+	if err != nil {
+		t.Fatalf("failed to marshal event: %s", err)
+	}
+	if err := s.write(conn, bts); err != nil {
+		t.Fatalf("failed to send event: %s", err)
+	}
+}
+
+// expectLog - waits for log update from the client
+func (s *fakeServer) expectLog(t *testing.T) *types.LogUpdateRequest {
+	t.Helper()
+	select {
+	case update := <-s.logs:
+		return update
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for log update")
+		return nil
+	}
+}
+
+// expectAction - waits for action request from the client
+func (s *fakeServer) expectAction(t *testing.T, action string) *types.ActionRequest {
+	t.Helper()
+	for {
+		select {
+		case req := <-s.actions:
+			if req.Action == action {
+				return req
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for '%s' action", action)
+			return nil
+		}
+	}
+}
+
+func TestStartRelaySubscribes(t *testing.T) {
+	srv := newFakeServer(t, "secret")
+	defer srv.Close()
+
+	c := NewDefaultClient(&Opts{
+		AccessKey:     "key",
+		AccessSecret:  "secret",
+		ServerAddress: srv.URL,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go c.StartRelay(ctx, &Filter{Buckets: []string{"foo", "bar"}})
+
+	srv.expectAction(t, "auth")
+	sub := srv.expectAction(t, "subscribe")
+	if len(sub.Buckets) != 2 || sub.Buckets[0] != "foo" || sub.Buckets[1] != "bar" {
+		t.Errorf("unexpected buckets: %v", sub.Buckets)
+	}
+
+	h := c.Health()
+	if !h.Connected || !h.Authenticated {
+		t.Errorf("expected connected and authenticated client, got: %+v", h)
+	}
+}
+
+func TestStartRelayUnauthorized(t *testing.T) {
+	srv := newFakeServer(t, "secret")
+	defer srv.Close()
+
+	c := NewDefaultClient(&Opts{
+		AccessKey:     "key",
+		AccessSecret:  "wrong",
+		ServerAddress: srv.URL,
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.StartRelay(context.Background(), &Filter{Buckets: []string{"foo"}})
+	}()
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Errorf("expected authentication error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("relay should stop when credentials are rejected")
+	}
+
+	if h := c.Health(); h.Authenticated || h.LastError == "" {
+		t.Errorf("unexpected health: %+v", h)
+	}
+}
+
+type countingForwarder struct {
+	calls int32
+}
+
+func (f *countingForwarder) Forward(wh types.Event) (*types.LogUpdateRequest, error) {
+	atomic.AddInt32(&f.calls, 1)
+	return &types.LogUpdateRequest{
+		ID:         wh.Meta.ID,
+		StatusCode: http.StatusOK,
+		Status:     types.RequestStatusSent,
+	}, nil
+}
+
+// forwarderFunc - forwarder implemented by a function
+type forwarderFunc func(wh types.Event) (*types.LogUpdateRequest, error)
+
+func (f forwarderFunc) Forward(wh types.Event) (*types.LogUpdateRequest, error) {
+	return f(wh)
+}
+
+func TestRedeliveredWebhookIsNotForwarded(t *testing.T) {
+	srv := newFakeServer(t, "secret")
+	defer srv.Close()
+
+	cache, _ := dedup.New(&dedup.Opts{})
+	fwd := &countingForwarder{}
+
+	c := NewDefaultClient(&Opts{
+		AccessKey:     "key",
+		AccessSecret:  "secret",
+		ServerAddress: srv.URL,
+		Forwarder:     fwd,
+		Dedup:         cache,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go c.StartRelay(ctx, &Filter{Buckets: []string{"foo"}})
+
+	srv.expectAction(t, "subscribe")
+	conn := <-srv.conns
+
+	event := &types.Event{
+		Type:   "webhook",
+		Meta:   types.EventMeta{ID: "event-1"},
+		Method: http.MethodPost,
+	}
+
+	srv.send(t, conn, event)
+	if update := srv.expectLog(t); update.ID != "event-1" || update.StatusCode != http.StatusOK {
+		t.Errorf("unexpected log update: %+v", update)
+	}
+
+	srv.send(t, conn, event)
+	if update := srv.expectLog(t); update.ID != "event-1" || update.StatusCode != http.StatusOK {
+		t.Errorf("unexpected log update for redelivered webhook: %+v", update)
+	}
+
+	if calls := atomic.LoadInt32(&fwd.calls); calls != 1 {
+		t.Errorf("expected webhook to be forwarded once, got: %d", calls)
+	}
+}
+
+func TestCompressedTransport(t *testing.T) {
+	cases := []struct {
+		name string
+		// advertise - server advertises compression in the handshake
+		advertise  bool
+		rejectGzip int
+		// expected Content-Encoding of requests for both log updates
+		encodings []string
+	}{
+		{name: "supported", advertise: true, encodings: []string{"gzip", "gzip"}},
+		{name: "rejected with 415", advertise: true, rejectGzip: http.StatusUnsupportedMediaType, encodings: []string{"gzip,", ""}},
+		{name: "rejected with 400", advertise: true, rejectGzip: http.StatusBadRequest, encodings: []string{"gzip,", ""}},
+		{name: "no handshake", rejectGzip: http.StatusBadRequest, encodings: []string{"", ""}},
+	}
+	for _, tc := range cases {
+		srv := newFakeServer(t, "secret")
+		srv.compression = tc.advertise
+		srv.rejectGzip = tc.rejectGzip
+		if tc.advertise {
+			srv.apiVersion = version.APIVersion
+			srv.capabilities = []string{types.CapabilityCompression}
+		}
+
+		c := NewDefaultClient(&Opts{
+			AccessKey:        "key",
+			AccessSecret:     "secret",
+			ServerAddress:    srv.URL,
+			Compression:      true,
+			CompressionLevel: 9,
+			GzipLogUpdates:   true,
+			Forwarder: forwarderFunc(func(wh types.Event) (*types.LogUpdateRequest, error) {
+				return &types.LogUpdateRequest{ID: wh.Meta.ID, StatusCode: 200, ResponseBody: []byte(wh.Body)}, nil
+			}),
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go c.StartRelay(ctx, &Filter{Buckets: []string{"foo"}})
+
+		if ext := <-srv.extensions; !strings.Contains(ext, "permessage-deflate") {
+			t.Errorf("%s: client should offer compression, got: %s", tc.name, ext)
+		}
+		conn := <-srv.conns
+		srv.expectAction(t, "subscribe")
+
+		body := strings.Repeat(`{"hello":"world"}`, 200)
+		for i, id := range []string{"1", "2"} {
+			srv.send(t, conn, &types.Event{Type: "webhook", Meta: types.EventMeta{ID: id}, Body: body})
+			update := srv.expectLog(t)
+			if string(update.ResponseBody) != body {
+				t.Errorf("%s: unexpected log update body", tc.name)
+			}
+
+			var encodings []string
+			for len(srv.encodings) > 0 {
+				encodings = append(encodings, <-srv.encodings)
+			}
+			if strings.Join(encodings, ",") != tc.encodings[i] {
+				t.Errorf("%s: expected log update %d encodings '%s', got: %v", tc.name, i, tc.encodings[i], encodings)
+			}
+		}
+
+		cancel()
+		srv.Close()
+	}
+}
+
+func TestSendLogsBatches(t *testing.T) {
+	for _, batch := range []bool{true, false} {
+		srv := newFakeServer(t, "secret")
+		srv.batchLogs = batch
+
+		c := NewDefaultClient(&Opts{
+			AccessKey:     "key",
+			AccessSecret:  "secret",
+			ServerAddress: srv.URL,
+		})
+
+		updates := []*types.LogUpdateRequest{
+			{ID: "1", StatusCode: 200},
+			{ID: "2", StatusCode: 500},
+		}
+		failed, _ := c.sendLogs(updates)
+		if len(failed) != 0 {
+			t.Errorf("unexpected failed updates: %v", failed)
+		}
+
+		got := map[string]int{}
+		for range updates {
+			update := srv.expectLog(t)
+			got[update.ID] = update.StatusCode
+		}
+		if got["1"] != 200 || got["2"] != 500 {
+			t.Errorf("unexpected log updates: %v", got)
+		}
+		if !batch && atomic.LoadInt32(&c.noBatchLogs) != 1 {
+			t.Errorf("expected batching to be disabled")
+		}
+		srv.Close()
+	}
+}
+
+func TestSendLogsRetries(t *testing.T) {
+	srv := newFakeServer(t, "secret")
+	defer srv.Close()
+	srv.logFailures = 2
+
+	c := NewDefaultClient(&Opts{
+		AccessKey:     "key",
+		AccessSecret:  "secret",
+		ServerAddress: srv.URL,
+		LogRetries:    1,
+	})
+	c.logClient.RetryWaitMin = time.Millisecond
+	c.logClient.RetryWaitMax = time.Millisecond
+
+	update := &types.LogUpdateRequest{ID: "1", StatusCode: 200}
+
+	// both attempts fail, update is returned to be queued again
+	failed, _ := c.sendLogs([]*types.LogUpdateRequest{update})
+	if len(failed) != 1 {
+		t.Fatalf("expected update to fail, got: %v", failed)
+	}
+
+	failed, _ = c.sendLogs(failed)
+	if len(failed) != 0 {
+		t.Fatalf("unexpected failed updates: %v", failed)
+	}
+	if srv.expectLog(t).ID != "1" {
+		t.Errorf("unexpected log update")
+	}
+}
+
+func TestSendLogsRejected(t *testing.T) {
+	for status, permanent := range map[int]bool{
+		http.StatusBadRequest:         true,
+		http.StatusUnauthorized:       true,
+		http.StatusNotFound:           true,
+		http.StatusRequestTimeout:     false,
+		http.StatusTooManyRequests:    false,
+		http.StatusServiceUnavailable: false,
+	} {
+		srv := newFakeServer(t, "secret")
+		srv.logFailures = 10
+		srv.logFailureStatus = status
+
+		c := NewDefaultClient(&Opts{
+			AccessKey:     "key",
+			AccessSecret:  "secret",
+			ServerAddress: srv.URL,
+			LogRetries:    1,
+		})
+		c.logClient.RetryWaitMin = time.Millisecond
+		c.logClient.RetryWaitMax = time.Millisecond
+
+		update := &types.LogUpdateRequest{ID: "1", StatusCode: 200}
+		failed, rejected := c.sendLogs([]*types.LogUpdateRequest{update})
+		if permanent && (len(rejected) != 1 || len(failed) != 0) {
+			t.Errorf("%d: expected update to be rejected, failed: %v, rejected: %v", status, failed, rejected)
+		}
+		if !permanent && (len(failed) != 1 || len(rejected) != 0) {
+			t.Errorf("%d: expected update to be retried, failed: %v, rejected: %v", status, failed, rejected)
+		}
+		srv.Close()
+	}
+}
+
+func TestWebsocketLogUpdates(t *testing.T) {
+	defer func(timeout time.Duration) { logProbeTimeout = timeout }(logProbeTimeout)
+	logProbeTimeout = 100 * time.Millisecond
+
+	for _, supported := range []bool{true, false} {
+		srv := newFakeServer(t, "secret")
+		srv.wsLogs = supported
+
+		c := NewDefaultClient(&Opts{
+			AccessKey:           "key",
+			AccessSecret:        "secret",
+			ServerAddress:       srv.URL,
+			WebsocketLogUpdates: true,
+			Forwarder:           &countingForwarder{},
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go c.StartRelay(ctx, &Filter{Buckets: []string{"foo"}})
+
+		conn := <-srv.conns
+		srv.expectAction(t, "subscribe")
+
+		for _, id := range []string{"1", "2"} {
+			srv.send(t, conn, &types.Event{Type: "webhook", Meta: types.EventMeta{ID: id}})
+			if update := srv.expectLog(t); update.ID != id {
+				t.Errorf("unexpected log update: %s", update.ID)
+			}
+		}
+
+		// HTTP is used only when server doesn't support websocket updates
+		httpUpdates := len(srv.encodings)
+		if supported && httpUpdates != 0 {
+			t.Errorf("expected log updates over websocket, got %d HTTP requests", httpUpdates)
+		}
+		if !supported && httpUpdates != 2 {
+			t.Errorf("expected HTTP fallback, got %d HTTP requests", httpUpdates)
+		}
+		// unacknowledged probe is not final, websocket is probed again later
+		if !supported && atomic.LoadInt32(&c.wsLogs) != wsLogsUnknown {
+			t.Errorf("expected websocket log update support to stay unknown")
+		}
+		if !supported && c.useWSLogs() {
+			t.Errorf("expected websocket log updates to be paused after probe timeout")
+		}
+
+		cancel()
+		srv.Close()
+	}
+}
+
+func TestCapabilityHandshake(t *testing.T) {
+	srv := newFakeServer(t, "secret")
+	defer srv.Close()
+	srv.apiVersion = "1"
+	srv.capabilities = []string{types.CapabilityUnsubscribe}
+
+	c := NewDefaultClient(&Opts{
+		AccessKey:           "key",
+		AccessSecret:        "secret",
+		ServerAddress:       srv.URL,
+		WebsocketLogUpdates: true,
+		GzipLogUpdates:      true,
+		Forwarder:           &countingForwarder{},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.StartRelay(ctx, &Filter{Buckets: []string{"foo", "bar"}})
+
+	conn := <-srv.conns
+	auth := srv.expectAction(t, "auth")
+	if auth.Version == "" || auth.APIVersion != version.APIVersion {
+		t.Errorf("unexpected auth versions: %q, %q", auth.Version, auth.APIVersion)
+	}
+	for _, capability := range []string{types.CapabilityBinaryBodies, types.CapabilityCompression, types.CapabilityLogUpdates, types.CapabilityUnsubscribe} {
+		if !contains(auth.Capabilities, capability) {
+			t.Errorf("expected '%s' capability in %v", capability, auth.Capabilities)
+		}
+	}
+	srv.expectAction(t, "subscribe")
+
+	// server didn't advertise log updates, so HTTP is used without probing
+	srv.send(t, conn, &types.Event{Type: "webhook", Meta: types.EventMeta{ID: "1"}})
+	start := time.Now()
+	srv.expectLog(t)
+	if elapsed := time.Since(start); elapsed > logProbeTimeout {
+		t.Errorf("log update took %s, expected no websocket probe", elapsed)
+	}
+	if encoding := <-srv.encodings; encoding != "" {
+		t.Errorf("expected plain log update without compression capability, got %q", encoding)
+	}
+
+	h := c.Health()
+	if h.ServerAPIVersion != "1" || len(h.ServerCapabilities) != 1 {
+		t.Errorf("unexpected server handshake: %q, %v", h.ServerAPIVersion, h.ServerCapabilities)
+	}
+
+	if err := c.Unsubscribe("foo"); err != nil {
+		t.Fatalf("failed to unsubscribe: %s", err)
+	}
+	if req := srv.expectAction(t, "unsubscribe"); len(req.Buckets) != 1 || req.Buckets[0] != "foo" {
+		t.Errorf("unexpected unsubscribe buckets: %v", req.Buckets)
+	}
+}
+
+func TestUnsubscribeUnsupported(t *testing.T) {
+	srv := newFakeServer(t, "secret")
+	defer srv.Close()
+
+	c := NewDefaultClient(&Opts{
+		AccessKey:     "key",
+		AccessSecret:  "secret",
+		ServerAddress: srv.URL,
+		Forwarder:     &countingForwarder{},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.StartRelay(ctx, &Filter{Buckets: []string{"foo"}})
+	srv.expectAction(t, "subscribe")
+
+	if err := c.Unsubscribe("foo"); err == nil {
+		t.Errorf("expected an error from server without unsubscribe support")
+	}
+	if h := c.Health(); h.ServerAPIVersion != "" || len(h.ServerCapabilities) != 0 {
+		t.Errorf("unexpected server handshake: %q, %v", h.ServerAPIVersion, h.ServerCapabilities)
+	}
+}
+
+func TestUnknownEventIsRejected(t *testing.T) {
+	srv := newFakeServer(t, "secret")
+	defer srv.Close()
+
+	c := NewDefaultClient(&Opts{
+		AccessKey:     "key",
+		AccessSecret:  "secret",
+		ServerAddress: srv.URL,
+		Forwarder:     &countingForwarder{},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.StartRelay(ctx, &Filter{Buckets: []string{"foo"}})
+
+	conn := <-srv.conns
+	srv.expectAction(t, "subscribe")
+
+	srv.send(t, conn, &types.Event{Type: "pause", RequestID: "7"})
+	ack := srv.expectAction(t, "ack")
+	if ack.RequestID != "7" || ack.Status != ackStatusUnsupported {
+		t.Errorf("unexpected ack: %q, %q", ack.RequestID, ack.Status)
+	}
+}
+
+type printingForwarder struct{}
+
+func (f *printingForwarder) Forward(wh types.Event) (*types.LogUpdateRequest, error) {
+	if wh.Meta.ID == "printed" {
+		return nil, nil
+	}
+	return &types.LogUpdateRequest{ID: wh.Meta.ID, StatusCode: http.StatusOK, Status: types.RequestStatusSent}, nil
+}
+
+func TestNilResultIsNotReported(t *testing.T) {
+	srv := newFakeServer(t, "secret")
+	defer srv.Close()
+
+	c := NewDefaultClient(&Opts{
+		AccessKey:     "key",
+		AccessSecret:  "secret",
+		ServerAddress: srv.URL,
+		Forwarder:     &printingForwarder{},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.StartRelay(ctx, &Filter{Buckets: []string{"foo"}})
+
+	conn := <-srv.conns
+	srv.expectAction(t, "subscribe")
+
+	srv.send(t, conn, &types.Event{Type: "webhook", Meta: types.EventMeta{ID: "printed"}})
+	srv.send(t, conn, &types.Event{Type: "webhook", Meta: types.EventMeta{ID: "forwarded"}})
+
+	if update := srv.expectLog(t); update.ID != "forwarded" {
+		t.Errorf("unexpected log update: %s", update.ID)
+	}
+	select {
+	case update := <-srv.logs:
+		t.Errorf("unexpected log update: %s", update.ID)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/mailru/easyjson"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// actionLogUpdate - action carrying delivery results over websocket
+const actionLogUpdate = "log_update"
+
+// ack statuses
+const (
+	ackStatusOK          = "ok"
+	ackStatusUnsupported = "unsupported"
+)
+
+// websocket log update support, advertised by the server or detected
+// with the first update
+const (
+	wsLogsUnknown int32 = iota
+	wsLogsSupported
+	wsLogsUnsupported
+)
+
+var (
+	// logProbeTimeout - how long to wait for the first acknowledgement
+	// from servers that didn't advertise websocket log updates
+	logProbeTimeout = 3 * time.Second
+	// logProbeBackoff - how long log updates are sent over HTTP after a
+	// probe timed out, before websocket is probed again
+	logProbeBackoff = 5 * time.Minute
+	// logAckTimeout - how long to wait for an acknowledgement
+	logAckTimeout = 10 * time.Second
+)
+
+var (
+	errWSLogsUnsupported  = errors.New("server does not support log updates over websocket")
+	errWSLogsProbeTimeout = errors.New("server did not acknowledge log updates over websocket")
+)
+
+// useWSLogs - whether log updates should be sent over websocket. Servers
+// that didn't advertise support are probed, at most once per
+// logProbeBackoff after a probe timed out
+func (c *DefaultClient) useWSLogs() bool {
+	if !c.opts.WebsocketLogUpdates || !c.Health().Authenticated {
+		return false
+	}
+	switch atomic.LoadInt32(&c.wsLogs) {
+	case wsLogsSupported:
+		return true
+	case wsLogsUnknown:
+		c.acksMu.Lock()
+		defer c.acksMu.Unlock()
+		return !time.Now().Before(c.wsLogsProbeAfter)
+	}
+	return false
+}
+
+// sendLogsWS - sends delivery results over websocket and waits for
+// server acknowledgement
+func (c *DefaultClient) sendLogsWS(updates []*types.LogUpdateRequest) error {
+	ackCh := make(chan *types.Event, 1)
+
+	c.acksMu.Lock()
+	c.requestID++
+	id := strconv.FormatUint(c.requestID, 10)
+	c.acks[id] = ackCh
+	c.acksMu.Unlock()
+	defer func() {
+		c.acksMu.Lock()
+		delete(c.acks, id)
+		c.acksMu.Unlock()
+	}()
+
+	bts, err := easyjson.Marshal(&types.ActionRequest{
+		Action:     actionLogUpdate,
+		RequestID:  id,
+		LogUpdates: updates,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal log update request: %s", err)
+	}
+
+	state := atomic.LoadInt32(&c.wsLogs)
+	timeout := logAckTimeout
+	if state == wsLogsUnknown {
+		timeout = logProbeTimeout
+	}
+
+	err = c.writeMessage(bts)
+	if err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case ack := <-ackCh:
+		switch ack.Status {
+		case ackStatusOK:
+			atomic.StoreInt32(&c.wsLogs, wsLogsSupported)
+			return nil
+		case ackStatusUnsupported:
+			return errWSLogsUnsupported
+		default:
+			atomic.CompareAndSwapInt32(&c.wsLogs, wsLogsUnknown, wsLogsSupported)
+			return fmt.Errorf("server rejected log updates: %s", ack.Message)
+		}
+	case <-timer.C:
+		if state == wsLogsUnknown {
+			// servers without websocket log updates ignore unknown
+			// actions, but so does a busy server, so support stays
+			// unknown and is probed again later
+			c.acksMu.Lock()
+			c.wsLogsProbeAfter = time.Now().Add(logProbeBackoff)
+			c.acksMu.Unlock()
+			return errWSLogsProbeTimeout
+		}
+		return fmt.Errorf("timed out waiting for log update acknowledgement")
+	}
+}
+
+// handleAck - passes acknowledgement to the waiting sender
+func (c *DefaultClient) handleAck(event *types.Event) {
+	c.acksMu.Lock()
+	ackCh, ok := c.acks[event.RequestID]
+	c.acksMu.Unlock()
+	if !ok {
+		c.logger.Debugw("received acknowledgement for unknown request",
+			"request_id", event.RequestID,
+		)
+		return
+	}
+	select {
+	case ackCh <- event:
+	default:
+	}
+}
@@ -0,0 +1,227 @@
+// Package config contains relayd configuration file definitions.
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/webhookrelay/relay-go/pkg/forward"
+	"github.com/webhookrelay/relay-go/pkg/proxy"
+	"github.com/webhookrelay/relay-go/pkg/redact"
+	"github.com/webhookrelay/relay-go/pkg/respond"
+	"github.com/webhookrelay/relay-go/pkg/rewrite"
+	"github.com/webhookrelay/relay-go/pkg/sink"
+	"github.com/webhookrelay/relay-go/pkg/tlsconfig"
+)
+
+// Config - relayd configuration file, example:
+//
+//	connections:
+//	  - name: eu
+//	    key: ${EU_RELAY_KEY}
+//	    secret: ${EU_RELAY_SECRET}
+//	    buckets: [github, stripe]
+//	  - name: us
+//	    key: ${US_RELAY_KEY}
+//	    secret: ${US_RELAY_SECRET}
+//	    server_address: https://us.webhookrelay.com:443
+//	    buckets: [billing]
+//	    retries: 5
+//	tls_profiles:
+//	  internal:
+//	    ca_file: /etc/relayd/internal-ca.pem
+//	    cert_file: /etc/relayd/client.pem
+//	    key_file: /etc/relayd/client-key.pem
+//	    min_version: "1.2"
+//	destination_tls:
+//	  - host: api.internal.corp
+//	    profile: internal
+//	destinations:
+//	  svc-a:
+//	    url: unix:///var/run/svc-a.sock:/webhooks
+//	  billing:
+//	    url: https://billing.internal.corp:8443
+//	    tls_profile: internal
+//	    timeout: 10s
+//	redaction:
+//	  headers: [Authorization, Cookie]
+//	  card_numbers: true
+//	  buckets:
+//	    stripe:
+//	      paths: [$.data.object.billing_details.email]
+//	sinks:
+//	  deploys: exec:///opt/deploy.sh?arg=production
+//	  billing: grpcs://billing.internal.corp:9443
+//	rewrite:
+//	  - host: localhost
+//	    destination: 'http://{{ env "APP_HOST" }}:{{ .Port }}{{ .Path }}'
+//	respond:
+//	  - bucket: github
+//	    status: 202
+//	    body: '{"received": "{{ .Meta.ID }}"}'
+type Config struct {
+	// Connections - independent Webhook Relay connections, when empty
+	// a single connection is configured from command line flags
+	Connections []*Connection `yaml:"connections"`
+	// TLSProfiles - named TLS settings that can be referenced by destinations
+	TLSProfiles map[string]*tlsconfig.Opts `yaml:"tls_profiles"`
+	// DestinationTLS - TLS profiles applied to destination hosts
+	DestinationTLS []DestinationTLS `yaml:"destination_tls"`
+	// Destinations - named destinations that webhook outputs can use
+	// instead of URLs, e.g. svc-a or svc-a/github
+	Destinations map[string]*Destination `yaml:"destinations"`
+	// Redaction - rules for removing secrets and personal data from
+	// webhooks before they are logged, persisted or exported
+	Redaction *redact.Config `yaml:"redaction"`
+	// Rewrite - destination templates, applied before webhooks
+	// are forwarded over HTTP
+	Rewrite []*rewrite.Rule `yaml:"rewrite"`
+	// Respond - canned responses used instead of forwarding webhooks
+	// when mock responder mode is enabled
+	Respond []*respond.Route `yaml:"respond"`
+	// Sinks - local destinations by bucket name or ID, such as files,
+	// commands or gRPC receivers, used instead of forwarding webhooks over HTTP
+	Sinks map[string]string `yaml:"sinks"`
+}
+
+// Connection - Webhook Relay connection with its own credentials, server
+// and forwarding settings. Empty fields default to command line flags
+type Connection struct {
+	Name string `yaml:"name"`
+	// Key and Secret - access token, ${VAR} references are
+	// expanded from environment variables
+	Key           string   `yaml:"key"`
+	Secret        string   `yaml:"secret"`
+	ServerAddress string   `yaml:"server_address"`
+	Buckets       []string `yaml:"buckets"`
+	Retries       *int     `yaml:"retries"`
+	Insecure      bool     `yaml:"insecure"`
+}
+
+// AccessKey - access key with expanded environment variables
+func (c *Connection) AccessKey() string {
+	return os.ExpandEnv(c.Key)
+}
+
+// AccessSecret - access secret with expanded environment variables
+func (c *Connection) AccessSecret() string {
+	return os.ExpandEnv(c.Secret)
+}
+
+// DestinationTLS - maps destination host to a TLS profile
+type DestinationTLS struct {
+	// Host - destination host, with an optional port
+	Host    string `yaml:"host"`
+	Profile string `yaml:"profile"`
+}
+
+// Destination - named destination URL with its transport settings
+type Destination struct {
+	// URL - http(s) URL or unix socket such as unix:///var/run/app.sock:/webhook
+	URL string `yaml:"url"`
+	// TLSProfile - optional TLS profile name
+	TLSProfile string `yaml:"tls_profile"`
+	Insecure   bool   `yaml:"insecure"`
+	// Proxy - optional proxy address, defaults to destination proxy
+	Proxy   string        `yaml:"proxy"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Load - reads and validates configuration file
+func Load(path string) (*Config, error) {
+	bts, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %s", err)
+	}
+	return Parse(bts)
+}
+
+// Parse - parses and validates configuration
+func Parse(data []byte) (*Config, error) {
+	var cfg Config
+	err := yaml.UnmarshalStrict(data, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config: %s", err)
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate - checks whether configuration is consistent
+func (c *Config) Validate() error {
+	names := make(map[string]bool, len(c.Connections))
+	for idx, conn := range c.Connections {
+		if conn == nil {
+			return fmt.Errorf("connection %d is empty", idx)
+		}
+		if conn.Name == "" {
+			conn.Name = fmt.Sprintf("connection-%d", idx)
+		}
+		if names[conn.Name] {
+			return fmt.Errorf("duplicate connection name '%s'", conn.Name)
+		}
+		names[conn.Name] = true
+		if conn.Retries != nil && *conn.Retries < 0 {
+			return fmt.Errorf("connection '%s': retries cannot be negative", conn.Name)
+		}
+	}
+	for name, profile := range c.TLSProfiles {
+		if profile == nil {
+			return fmt.Errorf("tls profile '%s' is empty", name)
+		}
+		if _, err := tlsconfig.New(profile); err != nil {
+			return fmt.Errorf("tls profile '%s': %s", name, err)
+		}
+	}
+	for _, d := range c.DestinationTLS {
+		if d.Host == "" {
+			return fmt.Errorf("destination_tls entry is missing host")
+		}
+		if _, ok := c.TLSProfiles[d.Profile]; !ok {
+			return fmt.Errorf("destination '%s' references unknown tls profile '%s'", d.Host, d.Profile)
+		}
+	}
+	for name, d := range c.Destinations {
+		if d == nil {
+			return fmt.Errorf("destination '%s' is empty", name)
+		}
+		fd := &forward.Destination{URL: d.URL, Timeout: d.Timeout}
+		if err := fd.Validate(); err != nil {
+			return fmt.Errorf("destination '%s': %s", name, err)
+		}
+		if d.TLSProfile != "" {
+			if _, ok := c.TLSProfiles[d.TLSProfile]; !ok {
+				return fmt.Errorf("destination '%s' references unknown tls profile '%s'", name, d.TLSProfile)
+			}
+		}
+		if d.Proxy != "" {
+			if _, err := proxy.Parse(d.Proxy); err != nil {
+				return fmt.Errorf("destination '%s': invalid proxy: %s", name, err)
+			}
+		}
+	}
+	if c.Redaction != nil {
+		if _, err := redact.NewPolicy(c.Redaction); err != nil {
+			return fmt.Errorf("redaction: %s", err)
+		}
+	}
+	if _, err := rewrite.New(&rewrite.Opts{Rules: c.Rewrite}); err != nil {
+		return fmt.Errorf("rewrite: %s", err)
+	}
+	if _, err := respond.New(&respond.Opts{Routes: c.Respond}); err != nil {
+		return fmt.Errorf("respond: %s", err)
+	}
+	router, err := sink.NewRouter(&sink.Opts{Buckets: c.Sinks})
+	if err != nil {
+		return fmt.Errorf("sinks: %s", err)
+	}
+	return router.Close()
+}
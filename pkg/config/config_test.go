@@ -0,0 +1,219 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse([]byte(`
+tls_profiles:
+  internal:
+    server_name: api.internal.corp
+    min_version: "1.2"
+destination_tls:
+  - host: api.internal.corp:8443
+    profile: internal
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.TLSProfiles["internal"].ServerName != "api.internal.corp" {
+		t.Errorf("unexpected server name: %s", cfg.TLSProfiles["internal"].ServerName)
+	}
+	if len(cfg.DestinationTLS) != 1 || cfg.DestinationTLS[0].Host != "api.internal.corp:8443" {
+		t.Errorf("unexpected destination TLS: %v", cfg.DestinationTLS)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	cases := map[string]struct {
+		config string
+		err    string
+	}{
+		"unknown field": {
+			config: "tls_profile: {}",
+			err:    "field tls_profile not found",
+		},
+		"unknown profile": {
+			config: "destination_tls: [{host: example.com, profile: missing}]",
+			err:    "unknown tls profile 'missing'",
+		},
+		"duplicate connection": {
+			config: "connections: [{name: eu}, {name: eu}]",
+			err:    "duplicate connection name 'eu'",
+		},
+		"invalid redaction pattern": {
+			config: "redaction: {buckets: {stripe: {patterns: ['(']}}}",
+			err:    "redaction: bucket 'stripe'",
+		},
+		"invalid profile": {
+			config: "tls_profiles: {internal: {min_version: '0.9'}}",
+			err:    "tls profile 'internal'",
+		},
+	}
+
+	for name, c := range cases {
+		_, err := Parse([]byte(c.config))
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: expected error containing '%s', got: %v", name, c.err, err)
+		}
+	}
+}
+
+func TestParseConnections(t *testing.T) {
+	os.Setenv("TEST_RELAY_SECRET", "s3cr3t")
+	defer os.Unsetenv("TEST_RELAY_SECRET")
+
+	cfg, err := Parse([]byte(`
+connections:
+  - name: eu
+    key: eu-key
+    secret: ${TEST_RELAY_SECRET}
+    buckets: [github, stripe]
+  - server_address: https://us.webhookrelay.com:443
+    buckets: [billing]
+    retries: 5
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(cfg.Connections) != 2 {
+		t.Fatalf("expected 2 connections, got: %d", len(cfg.Connections))
+	}
+	eu := cfg.Connections[0]
+	if eu.AccessKey() != "eu-key" || eu.AccessSecret() != "s3cr3t" {
+		t.Errorf("unexpected credentials: %s/%s", eu.AccessKey(), eu.AccessSecret())
+	}
+	if eu.Retries != nil {
+		t.Errorf("expected retries to be unset")
+	}
+	us := cfg.Connections[1]
+	if us.Name != "connection-1" {
+		t.Errorf("expected default connection name, got: %s", us.Name)
+	}
+	if us.Retries == nil || *us.Retries != 5 {
+		t.Errorf("unexpected retries: %v", us.Retries)
+	}
+}
+
+func TestParseRedaction(t *testing.T) {
+	cfg, err := Parse([]byte(`
+redaction:
+  headers: [Authorization]
+  card_numbers: true
+  buckets:
+    stripe:
+      paths: [$.data.object.email]
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !cfg.Redaction.CardNumbers || len(cfg.Redaction.Headers) != 1 {
+		t.Errorf("unexpected global rules: %+v", cfg.Redaction.Rules)
+	}
+	if cfg.Redaction.Buckets["stripe"].Paths[0] != "$.data.object.email" {
+		t.Errorf("unexpected bucket rules: %+v", cfg.Redaction.Buckets)
+	}
+}
+
+func TestParseRespond(t *testing.T) {
+	cfg, err := Parse([]byte(`
+respond:
+  - bucket: github
+    status: 202
+    latency: 150ms
+    failure_rate: 0.1
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	route := cfg.Respond[0]
+	if route.Bucket != "github" || route.Status != 202 || route.Latency != 150*time.Millisecond || route.FailureRate != 0.1 {
+		t.Errorf("unexpected route: %+v", route)
+	}
+
+	if _, err := Parse([]byte("respond:\n  - failure_rate: 3\n")); err == nil {
+		t.Errorf("expected an error for invalid route")
+	}
+}
+
+func TestParseSinks(t *testing.T) {
+	cfg, err := Parse([]byte(`
+sinks:
+  deploys: exec:///opt/deploy.sh?arg=production
+  audit: file:///var/log/webhooks.jsonl
+  billing: grpcs://billing.internal.corp:9443
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(cfg.Sinks) != 3 || cfg.Sinks["audit"] != "file:///var/log/webhooks.jsonl" {
+		t.Errorf("unexpected sinks: %v", cfg.Sinks)
+	}
+
+	if _, err := Parse([]byte("sinks:\n  deploys: ftp://host/file\n")); err == nil {
+		t.Errorf("expected an error for unknown sink scheme")
+	}
+}
+
+func TestParseDestinations(t *testing.T) {
+	cfg, err := Parse([]byte(`
+tls_profiles:
+  internal:
+    min_version: "1.2"
+destinations:
+  svc-a:
+    url: unix:///var/run/svc-a.sock:/webhooks
+  billing:
+    url: https://billing.internal.corp:8443
+    tls_profile: internal
+    timeout: 10s
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(cfg.Destinations) != 2 {
+		t.Fatalf("unexpected destinations: %v", cfg.Destinations)
+	}
+	if d := cfg.Destinations["billing"]; d.TLSProfile != "internal" || d.Timeout != 10*time.Second {
+		t.Errorf("unexpected billing destination: %+v", d)
+	}
+
+	for _, invalid := range []string{
+		"destinations:\n  svc-a:\n    url: ftp://svc-a\n",
+		"destinations:\n  svc-a:\n    url: http://svc-a\n    tls_profile: missing\n",
+		"destinations:\n  svc-a:\n    url: unix://\n",
+		"destinations:\n  svc-a:\n",
+	} {
+		if _, err := Parse([]byte(invalid)); err == nil {
+			t.Errorf("expected an error for: %s", invalid)
+		}
+	}
+}
+
+func TestParseRewrite(t *testing.T) {
+	os.Setenv("RELAY_TEST_APP_HOST", "app")
+	defer os.Unsetenv("RELAY_TEST_APP_HOST")
+
+	cfg, err := Parse([]byte(`
+rewrite:
+  - host: localhost
+    destination: 'http://{{ env "RELAY_TEST_APP_HOST" }}:{{ .Port }}{{ .Path }}'
+  - bucket: github
+    destination: 'http://ci:8080/hooks/{{ .Meta.BucketName }}'
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(cfg.Rewrite) != 2 || cfg.Rewrite[1].Bucket != "github" {
+		t.Errorf("unexpected rewrite rules: %v", cfg.Rewrite)
+	}
+
+	os.Unsetenv("RELAY_TEST_APP_HOST")
+	if _, err := Parse([]byte("rewrite:\n  - destination: 'http://{{ env \"RELAY_TEST_APP_HOST\" }}'\n")); err == nil {
+		t.Errorf("expected an error for unset environment variable")
+	}
+}
@@ -0,0 +1,251 @@
+// Package dedup remembers recently delivered events so webhooks that are
+// redelivered after reconnects are not forwarded twice.
+package dedup
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// defaults
+var (
+	DefaultSize = 10000
+	DefaultTTL  = time.Hour
+)
+
+// Opts - cache configuration
+type Opts struct {
+	// Size - maximum number of remembered events
+	Size int
+	// TTL - how long delivery results are remembered
+	TTL time.Duration
+	// Path - optional file to persist delivery results across restarts
+	Path string
+}
+
+// Cache - LRU cache of delivery results keyed by event ID
+type Cache struct {
+	opts *Opts
+
+	mu       sync.Mutex
+	ll       *list.List
+	items    map[string]*list.Element
+	inFlight map[string]bool
+	file     *os.File
+	// appended - entries appended to the file since it was compacted
+	appended int
+}
+
+type entry struct {
+	ID       string                  `json:"id"`
+	Added    time.Time               `json:"added"`
+	Response *types.LogUpdateRequest `json:"response"`
+}
+
+// New - creates cache, previously persisted results are loaded from Path
+func New(opts *Opts) (*Cache, error) {
+	if opts.Size <= 0 {
+		opts.Size = DefaultSize
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTTL
+	}
+
+	c := &Cache{
+		opts:     opts,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+		inFlight: make(map[string]bool),
+	}
+
+	if opts.Path != "" {
+		err := c.load()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Start - marks event as being delivered. Returns cached delivery result
+// and false when event was already delivered. When event is still being
+// delivered, nil result and false are returned. True is returned only for
+// the first delivery which must be completed with Finish or Abort
+func (c *Cache) Start(id string) (*types.LogUpdateRequest, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[id]; ok {
+		e := el.Value.(*entry)
+		if time.Since(e.Added) < c.opts.TTL {
+			return e.Response, false
+		}
+		c.remove(el)
+	}
+	if c.inFlight[id] {
+		return nil, false
+	}
+	c.inFlight[id] = true
+	return nil, true
+}
+
+// Finish - stores delivery result. Only successful deliveries are
+// remembered, failed ones are aborted so redeliveries are forwarded again
+func (c *Cache) Finish(id string, resp *types.LogUpdateRequest) error {
+	if resp == nil || resp.Status != types.RequestStatusSent {
+		c.Abort(id)
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.inFlight, id)
+	e := &entry{ID: id, Added: time.Now(), Response: resp}
+	c.add(e)
+
+	if c.file == nil {
+		return nil
+	}
+	// file is rewritten once it holds as many stale entries as the
+	// cache can, so it doesn't grow in long running processes
+	if c.appended >= c.opts.Size {
+		return c.compact()
+	}
+	bts, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = c.file.Write(append(bts, '\n'))
+	if err == nil {
+		c.appended++
+	}
+	return err
+}
+
+// Abort - forgets event that failed to be delivered so it can be retried
+func (c *Cache) Abort(id string) {
+	c.mu.Lock()
+	delete(c.inFlight, id)
+	c.mu.Unlock()
+}
+
+// Len - number of remembered events
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// Close - closes persistence file
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
+
+func (c *Cache) add(e *entry) {
+	if el, ok := c.items[e.ID]; ok {
+		c.remove(el)
+	}
+	c.items[e.ID] = c.ll.PushFront(e)
+	for c.ll.Len() > c.opts.Size {
+		c.remove(c.ll.Back())
+	}
+}
+
+func (c *Cache) remove(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*entry).ID)
+}
+
+// load - reads persisted entries and compacts the file, dropping
+// expired and evicted entries
+func (c *Cache) load() error {
+	f, err := os.Open(c.opts.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to open dedup file: %s", err)
+	}
+	if err == nil {
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var e entry
+			if json.Unmarshal(scanner.Bytes(), &e) != nil || e.Response == nil {
+				// skipping partially written lines
+				continue
+			}
+			if time.Since(e.Added) >= c.opts.TTL {
+				continue
+			}
+			e.Response.ID = e.ID
+			c.add(&e)
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("failed to read dedup file: %s", err)
+		}
+	}
+
+	return c.compact()
+}
+
+// compact - rewrites the file with remembered entries only
+func (c *Cache) compact() error {
+	if c.file != nil {
+		c.file.Close()
+		c.file = nil
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(c.opts.Path), filepath.Base(c.opts.Path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to compact dedup file: %s", err)
+	}
+	w := bufio.NewWriter(tmp)
+	for el := c.ll.Back(); el != nil; el = el.Prev() {
+		e := el.Value.(*entry)
+		if time.Since(e.Added) >= c.opts.TTL {
+			continue
+		}
+		bts, err := json.Marshal(e)
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return err
+		}
+		w.Write(append(bts, '\n'))
+	}
+	err = w.Flush()
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.opts.Path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to compact dedup file: %s", err)
+	}
+
+	c.file, err = os.OpenFile(c.opts.Path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open dedup file: %s", err)
+	}
+	c.appended = 0
+	return nil
+}
@@ -0,0 +1,167 @@
+package dedup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+func TestCacheStartFinish(t *testing.T) {
+	c, err := New(&Opts{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, first := c.Start("1"); !first {
+		t.Fatalf("expected first delivery")
+	}
+	if resp, first := c.Start("1"); first || resp != nil {
+		t.Errorf("expected in flight delivery to be skipped")
+	}
+
+	c.Finish("1", &types.LogUpdateRequest{ID: "1", StatusCode: 200, Status: types.RequestStatusSent})
+
+	resp, first := c.Start("1")
+	if first {
+		t.Fatalf("expected delivered event to be detected")
+	}
+	if resp == nil || resp.StatusCode != 200 {
+		t.Errorf("unexpected cached response: %v", resp)
+	}
+}
+
+func TestCacheAbort(t *testing.T) {
+	c, _ := New(&Opts{})
+
+	c.Start("1")
+	c.Abort("1")
+
+	if _, first := c.Start("1"); !first {
+		t.Errorf("aborted delivery should be retried")
+	}
+}
+
+func TestCacheFailedDeliveryIsNotRemembered(t *testing.T) {
+	c, _ := New(&Opts{})
+
+	c.Start("1")
+	c.Finish("1", &types.LogUpdateRequest{ID: "1", StatusCode: 503, Status: types.RequestStatusFailed})
+	if _, first := c.Start("1"); !first {
+		t.Errorf("failed delivery should be retried")
+	}
+
+	c.Finish("1", nil)
+	if _, first := c.Start("1"); !first {
+		t.Errorf("delivery without result should be retried")
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	c, _ := New(&Opts{Size: 2})
+
+	for _, id := range []string{"1", "2", "3"} {
+		c.Start(id)
+		c.Finish(id, &types.LogUpdateRequest{ID: id, Status: types.RequestStatusSent})
+	}
+
+	if c.Len() != 2 {
+		t.Errorf("expected 2 entries, got: %d", c.Len())
+	}
+	if _, first := c.Start("1"); !first {
+		t.Errorf("oldest entry should have been evicted")
+	}
+	if _, first := c.Start("3"); first {
+		t.Errorf("newest entry should be remembered")
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	c, _ := New(&Opts{TTL: 50 * time.Millisecond})
+
+	c.Start("1")
+	c.Finish("1", &types.LogUpdateRequest{ID: "1", Status: types.RequestStatusSent})
+
+	time.Sleep(60 * time.Millisecond)
+
+	if _, first := c.Start("1"); !first {
+		t.Errorf("expired entry should be forgotten")
+	}
+}
+
+func TestCachePersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dedup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "dedup.jsonl")
+
+	c, err := New(&Opts{Path: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	c.Start("1")
+	c.Finish("1", &types.LogUpdateRequest{
+		ID:           "1",
+		StatusCode:   202,
+		ResponseBody: []byte("accepted"),
+		Status:       types.RequestStatusSent,
+	})
+	c.Close()
+
+	restored, err := New(&Opts{Path: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer restored.Close()
+
+	resp, first := restored.Start("1")
+	if first {
+		t.Fatalf("expected persisted event to be remembered")
+	}
+	if resp.ID != "1" || resp.StatusCode != 202 || string(resp.ResponseBody) != "accepted" || resp.Status != types.RequestStatusSent {
+		t.Errorf("unexpected restored response: %+v", resp)
+	}
+}
+
+func TestCachePersistenceCompaction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dedup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "dedup.jsonl")
+
+	c, err := New(&Opts{Path: path, Size: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 100; i++ {
+		id := strconv.Itoa(i)
+		c.Start(id)
+		if err := c.Finish(id, &types.LogUpdateRequest{ID: id, Status: types.RequestStatusSent}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	bts, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(bts), "\n"); lines > 20 {
+		t.Errorf("expected file to be compacted, got %d lines", lines)
+	}
+	if _, first := c.Start("99"); first {
+		t.Errorf("expected latest entry to be remembered")
+	}
+}
@@ -0,0 +1,28 @@
+package forward
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBuffer - buffers that grew larger than this are not returned
+// to the pool so a single large response doesn't pin memory
+const maxPooledBuffer = 4 << 20
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
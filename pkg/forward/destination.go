@@ -0,0 +1,253 @@
+package forward
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/webhookrelay/relay-go/pkg/proxy"
+	"github.com/webhookrelay/relay-go/pkg/retryablehttp"
+)
+
+// unixScheme - destinations such as unix:///var/run/app.sock:/webhook are
+// sent over the unix socket, path after the colon is the request path
+const unixScheme = "unix://"
+
+// unixHost - Host header of requests sent over unix sockets
+const unixHost = "localhost"
+
+// Destination - named destination, webhooks which output destination is
+// the name (svc-a, svc-a/path) or a URL with the name as host
+// (http://svc-a/path) are sent to the destination URL instead
+type Destination struct {
+	// URL - http(s) or unix socket URL, path of the webhook destination
+	// is appended to it
+	URL string
+	// TLSConfig - optional TLS settings, defaults to forwarder TLS settings
+	TLSConfig *tls.Config
+	Insecure  bool
+	// Proxy - optional proxy, defaults to forwarder proxy
+	Proxy proxy.Func
+	// Timeout - optional request timeout, 0 means no timeout
+	Timeout time.Duration
+}
+
+// namedDestination - destination with its own client
+type namedDestination struct {
+	base   string
+	client *retryablehttp.Client
+}
+
+// Validate - checks whether destination URL is supported, paths are
+// appended to it so it cannot have a query or fragment
+func (d *Destination) Validate() error {
+	err := validateURL(d.URL)
+	if err != nil {
+		return err
+	}
+	if strings.ContainsAny(d.URL, "?#") {
+		return fmt.Errorf("destination URL cannot have query or fragment")
+	}
+	if d.Timeout < 0 {
+		return fmt.Errorf("timeout cannot be negative")
+	}
+	return nil
+}
+
+// validateURL - checks whether URL can be used as a destination
+func validateURL(rawURL string) error {
+	if strings.HasPrefix(rawURL, unixScheme) {
+		_, _, err := parseUnixURL(rawURL)
+		return err
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme '%s', supported schemes: http, https, unix", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("destination '%s' is missing host", rawURL)
+	}
+	return nil
+}
+
+// parseUnixURL - splits unix:///var/run/app.sock:/webhook?x=1 into socket
+// path and http://localhost/webhook?x=1 request URL
+func parseUnixURL(rawURL string) (socket, target string, err error) {
+	rest := strings.TrimPrefix(rawURL, unixScheme)
+
+	var path string
+	if idx := strings.Index(rest, ":"); idx >= 0 {
+		socket, path = rest[:idx], rest[idx+1:]
+	} else if idx := strings.Index(rest, "?"); idx >= 0 {
+		socket, path = rest[:idx], rest[idx:]
+	} else {
+		socket = rest
+	}
+	if socket == "" {
+		return "", "", fmt.Errorf("destination '%s' is missing socket path, e.g. unix:///var/run/app.sock:/webhook", rawURL)
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	u, err := url.Parse("http://" + unixHost + path)
+	if err != nil {
+		return "", "", err
+	}
+	return socket, u.String(), nil
+}
+
+// newUnixClient - creates client that sends all requests over the socket
+func newUnixClient(opts *Opts, socket string) *retryablehttp.Client {
+	client := newRetryableClient(opts, nil)
+	tr := client.HTTPClient.Transport.(*http.Transport)
+	tr.Proxy = nil
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	tr.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "unix", socket)
+	}
+	return client
+}
+
+// hostTLSConfig - TLS settings of a specific destination with forwarder
+// minimum TLS version and insecure override applied
+func hostTLSConfig(cfg, defaults *tls.Config, insecure bool) *tls.Config {
+	cfg = cfg.Clone()
+	if cfg.MinVersion < defaults.MinVersion {
+		cfg.MinVersion = defaults.MinVersion
+	}
+	if insecure {
+		cfg.InsecureSkipVerify = true
+	}
+	return cfg
+}
+
+// newNamedDestinations - creates clients for named destinations
+func newNamedDestinations(opts *Opts, tlsConfig *tls.Config) (map[string]*namedDestination, error) {
+	named := make(map[string]*namedDestination, len(opts.Destinations))
+	for name, d := range opts.Destinations {
+		if d == nil {
+			return nil, fmt.Errorf("destination '%s' is empty", name)
+		}
+		err := d.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("destination '%s': %s", name, err)
+		}
+
+		dopts := *opts
+		if d.Proxy != nil {
+			dopts.Proxy = d.Proxy
+		}
+		dtls := tlsConfig
+		if d.TLSConfig != nil {
+			dtls = hostTLSConfig(d.TLSConfig, tlsConfig, opts.Insecure)
+		}
+		if d.Insecure {
+			dtls = dtls.Clone()
+			dtls.InsecureSkipVerify = true
+		}
+
+		base := strings.TrimSuffix(d.URL, "/")
+		var client *retryablehttp.Client
+		if strings.HasPrefix(d.URL, unixScheme) {
+			socket, _, _ := parseUnixURL(d.URL)
+			client = newUnixClient(&dopts, socket)
+			if !strings.Contains(strings.TrimPrefix(base, unixScheme), ":") {
+				// paths are appended after the socket path separator
+				base += ":"
+			}
+		} else {
+			client = newRetryableClient(&dopts, dtls)
+		}
+		client.HTTPClient.Timeout = d.Timeout
+
+		named[strings.ToLower(name)] = &namedDestination{
+			base:   base,
+			client: client,
+		}
+	}
+	return named, nil
+}
+
+// errUnixSocketNotAllowed - webhook destination is a unix socket that
+// doesn't come from a named destination
+var errUnixSocketNotAllowed = errors.New("unix socket destinations are only allowed for named destinations unless --allow-unix-socket is set")
+
+// resolve - returns request URL and client for the webhook destination,
+// resolving named destinations and unix sockets. Unix sockets set by the
+// server are only dialed when AllowUnixSockets is enabled
+func (r *DefaultForwarder) resolve(destination string) (string, *retryablehttp.Client, error) {
+	var client *retryablehttp.Client
+	target := destination
+
+	if d, rest, ok := r.namedDestination(destination); ok {
+		target, client = d.base+rest, d.client
+	}
+
+	if strings.HasPrefix(target, unixScheme) {
+		socket, u, err := parseUnixURL(target)
+		if err != nil {
+			return "", nil, err
+		}
+		if client == nil {
+			if !r.opts.AllowUnixSockets {
+				return "", nil, errUnixSocketNotAllowed
+			}
+			client = r.unixClient(socket)
+		}
+		return u, client, nil
+	}
+	return target, client, nil
+}
+
+// namedDestination - matches destination such as svc-a/path or
+// http://svc-a/path against named destinations, returning the rest of it
+func (r *DefaultForwarder) namedDestination(destination string) (*namedDestination, string, bool) {
+	if len(r.destinations) == 0 {
+		return nil, "", false
+	}
+
+	if !strings.Contains(destination, "://") {
+		name, rest := destination, ""
+		if idx := strings.IndexAny(destination, "/?"); idx >= 0 {
+			name, rest = destination[:idx], destination[idx:]
+		}
+		d, ok := r.destinations[strings.ToLower(name)]
+		return d, rest, ok
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, "", false
+	}
+	d, ok := r.destinations[strings.ToLower(u.Host)]
+	if !ok {
+		return nil, "", false
+	}
+	rest := u.EscapedPath()
+	if u.RawQuery != "" {
+		rest += "?" + u.RawQuery
+	}
+	return d, rest, true
+}
+
+// unixClient - returns client for the socket, creating it on first use
+func (r *DefaultForwarder) unixClient(socket string) *retryablehttp.Client {
+	r.unixMu.Lock()
+	defer r.unixMu.Unlock()
+	if c, ok := r.unixClients[socket]; ok {
+		return c
+	}
+	c := newUnixClient(r.opts, socket)
+	r.unixClients[socket] = c
+	return c
+}
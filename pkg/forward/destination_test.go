@@ -0,0 +1,176 @@
+package forward
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// newUnixServer - starts HTTP server on a unix socket, handler
+// responds with request path and query
+func newUnixServer(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "relay-unix")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	socket := filepath.Join(dir, "app.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("failed to listen: %s", err)
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Method + " " + r.URL.RequestURI()))
+	}))
+	ts.Listener = l
+	ts.Start()
+	return socket, func() {
+		ts.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestParseUnixURL(t *testing.T) {
+	cases := []struct {
+		url    string
+		socket string
+		target string
+		err    bool
+	}{
+		{url: "unix:///var/run/app.sock:/webhook", socket: "/var/run/app.sock", target: "http://localhost/webhook"},
+		{url: "unix:///var/run/app.sock:/webhook?x=1", socket: "/var/run/app.sock", target: "http://localhost/webhook?x=1"},
+		{url: "unix:///var/run/app.sock", socket: "/var/run/app.sock", target: "http://localhost/"},
+		{url: "unix:///var/run/app.sock?x=1", socket: "/var/run/app.sock", target: "http://localhost/?x=1"},
+		{url: "unix://app.sock:webhook", socket: "app.sock", target: "http://localhost/webhook"},
+		{url: "unix://:/webhook", err: true},
+	}
+	for _, c := range cases {
+		socket, target, err := parseUnixURL(c.url)
+		if c.err {
+			if err == nil {
+				t.Errorf("%s: expected error", c.url)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", c.url, err)
+			continue
+		}
+		if socket != c.socket || target != c.target {
+			t.Errorf("%s: unexpected socket '%s' and target '%s'", c.url, socket, target)
+		}
+	}
+}
+
+func TestRelayUnixSocket(t *testing.T) {
+	socket, stop := newUnixServer(t)
+	defer stop()
+
+	dr := NewDefaultForwarder(&Opts{Retries: 0, AllowUnixSockets: true})
+	ws, err := dr.Forward(types.Event{
+		Meta: types.EventMeta{
+			OutputDestination: "unix://" + socket + ":/webhook",
+		},
+		RawQuery: "x=1",
+		Method:   http.MethodPost,
+		Body:     "hi",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ws.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: %d, body: %s", ws.StatusCode, ws.ResponseBody)
+	}
+	if string(ws.ResponseBody) != "POST /webhook?x=1" {
+		t.Errorf("unexpected response: %s", ws.ResponseBody)
+	}
+
+	dr = NewDefaultForwarder(&Opts{Retries: 0})
+	ws, err = dr.Forward(types.Event{
+		Meta: types.EventMeta{
+			ID:                "1",
+			OutputDestination: "unix://" + socket + ":/webhook",
+		},
+		Method: http.MethodPost,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ws.Status != types.RequestStatusFailed || ws.StatusCode != 0 || ws.ID != "1" {
+		t.Errorf("expected unix socket destination to be rejected, got %d: %s", ws.StatusCode, ws.ResponseBody)
+	}
+}
+
+func TestRelayNamedDestinations(t *testing.T) {
+	socket, stop := newUnixServer(t)
+	defer stop()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tcp " + r.URL.RequestURI()))
+	}))
+	defer ts.Close()
+
+	dr := NewDefaultForwarder(&Opts{
+		Retries: 0,
+		Destinations: map[string]*Destination{
+			"svc-a": {URL: "unix://" + socket},
+			"svc-b": {URL: ts.URL + "/hooks/", Timeout: time.Second},
+		},
+	})
+
+	cases := []struct {
+		destination string
+		query       string
+		response    string
+	}{
+		{destination: "svc-a", response: "PUT /"},
+		{destination: "svc-a/github", query: "x=1", response: "PUT /github?x=1"},
+		{destination: "http://SVC-A/github", response: "PUT /github"},
+		{destination: "svc-b", response: "tcp /hooks"},
+		{destination: "svc-b/stripe", query: "x=1", response: "tcp /hooks/stripe?x=1"},
+		{destination: "https://svc-b/stripe?y=2", response: "tcp /hooks/stripe?y=2"},
+		{destination: ts.URL + "/direct", response: "tcp /direct"},
+	}
+	for _, c := range cases {
+		ws, err := dr.Forward(types.Event{
+			Meta:     types.EventMeta{OutputDestination: c.destination},
+			RawQuery: c.query,
+			Method:   http.MethodPut,
+		})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", c.destination, err)
+			continue
+		}
+		if string(ws.ResponseBody) != c.response {
+			t.Errorf("%s: unexpected response: %s", c.destination, ws.ResponseBody)
+		}
+	}
+}
+
+func TestInvalidNamedDestination(t *testing.T) {
+	_, err := newNamedDestinations(&Opts{
+		Destinations: map[string]*Destination{
+			"svc-a": {URL: "ftp://svc-a"},
+		},
+	}, nil)
+	if err == nil {
+		t.Errorf("expected unsupported scheme to be rejected")
+	}
+	for _, d := range []*Destination{
+		{URL: "http://"},
+		{URL: "http://svc-a/hooks?x=1"},
+		{URL: "unix://"},
+		{URL: "http://svc-a", Timeout: -time.Second},
+	} {
+		if err := d.Validate(); err == nil {
+			t.Errorf("expected destination '%s' to be rejected", d.URL)
+		}
+	}
+}
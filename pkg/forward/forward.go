@@ -1,28 +1,66 @@
 package forward
 
 import (
-	"bytes"
 	"crypto/tls"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/webhookrelay/relay-go/pkg/proxy"
+	"github.com/webhookrelay/relay-go/pkg/redact"
 	"github.com/webhookrelay/relay-go/pkg/retryablehttp"
 	"github.com/webhookrelay/relay-go/pkg/types"
 	"go.uber.org/zap"
 )
 
-// Forwarder is responsible for receiving and processing incoming webhook events
+// Forwarder is responsible for receiving and processing incoming webhook events,
+// returned result is reported back to Webhook Relay unless it is nil
 type Forwarder interface {
 	Forward(wh types.Event) (*types.LogUpdateRequest, error)
 }
 
 var _ Forwarder = &DefaultForwarder{}
 
+// idempotency headers, set to event ID
+const (
+	HeaderWebhookID      = "X-Webhook-Id"
+	HeaderIdempotencyKey = "Idempotency-Key"
+)
+
+// DefaultMaxResponseBody - default maximum captured response body size
+const DefaultMaxResponseBody = 1 << 20
+
+// response body markers reported to Webhook Relay instead of the body
+const (
+	truncatedMarker = "\n...[truncated, response body exceeded %d bytes]"
+	discardedMarker = "[response body not captured]"
+	withheldMarker  = "[response body withheld, it could not be parsed for redaction]"
+)
+
 // DefaultForwarder - default 'last mile' webhook Forwarder
 type DefaultForwarder struct {
 	hClient *http.Client
 	rClient *retryablehttp.Client
+	// clients for destination hosts that have dedicated TLS settings
+	hostClients map[string]*retryablehttp.Client
+	// named destinations and lazily created unix socket clients
+	destinations map[string]*namedDestination
+	unixMu       sync.Mutex
+	unixClients  map[string]*retryablehttp.Client
+	opts         *Opts
+
+	idempotencyHeaders bool
+	metadataHeaders    bool
+	headerPolicy       *HeaderPolicy
+
+	maxResponseBody     int64
+	discardResponseBody bool
+	responseRedactor    *redact.Redactor
 
 	logger *zap.SugaredLogger
 }
@@ -31,14 +69,43 @@ type DefaultForwarder struct {
 type Opts struct {
 	Retries  int
 	Insecure bool
-	Logger   *zap.SugaredLogger
+	// Proxy - optional proxy for destination requests, defaults
+	// to HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+	Proxy proxy.Func
+	// TLSConfig - optional TLS settings for destinations (CA bundle,
+	// client certificates for mutual TLS, minimum version)
+	TLSConfig *tls.Config
+	// HostTLSConfigs - TLS settings for specific destination hosts, keyed
+	// by host or host:port. These take precedence over TLSConfig
+	HostTLSConfigs map[string]*tls.Config
+	// Destinations - named destinations, keyed by name, that webhook
+	// output destinations can refer to instead of URLs
+	Destinations map[string]*Destination
+	// AllowUnixSockets - allow unix:// webhook output destinations,
+	// otherwise only named destinations can use unix sockets
+	AllowUnixSockets bool
+	// IdempotencyHeaders - add X-Webhook-Id and Idempotency-Key headers
+	// with event ID so destinations can detect redelivered webhooks
+	IdempotencyHeaders bool
+	// MetadataHeaders - add X-Relay-* headers with event ID, bucket, input,
+	// output, attempt number and time when webhook was received
+	MetadataHeaders bool
+	// HeaderPolicy - optional allow/deny list for incoming webhook headers
+	HeaderPolicy *HeaderPolicy
+	// MaxResponseBody - maximum response body size in bytes reported back
+	// to Webhook Relay, larger bodies are truncated. 0 means no limit
+	MaxResponseBody int64
+	// DiscardResponseBody - never report response body back to Webhook Relay
+	DiscardResponseBody bool
+	// ResponseRedactor - optional redaction of response headers and JSON
+	// body fields before they are reported back to Webhook Relay
+	ResponseRedactor *redact.Redactor
+	Logger           *zap.SugaredLogger
 }
 
 // NewDefaultForwarder - create an instance of default Forwarder
 func NewDefaultForwarder(opts *Opts) *DefaultForwarder {
 
-	httpClient := &http.Client{}
-
 	if opts.Logger == nil {
 		cfg := zap.NewProductionConfig()
 		cfg.DisableCaller = true
@@ -52,20 +119,77 @@ func NewDefaultForwarder(opts *Opts) *DefaultForwarder {
 		opts.Logger = l.Sugar()
 	}
 
-	client := retryablehttp.NewClient(opts.Logger)
-
+	tlsConfig := &tls.Config{}
+	if opts.TLSConfig != nil {
+		tlsConfig = opts.TLSConfig.Clone()
+	}
 	if opts.Insecure {
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-		insecureClient := &http.Client{Transport: tr}
-		client.HTTPClient = insecureClient
-		httpClient = insecureClient
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	client := newRetryableClient(opts, tlsConfig)
+
+	hostClients := make(map[string]*retryablehttp.Client, len(opts.HostTLSConfigs))
+	for host, cfg := range opts.HostTLSConfigs {
+		hostClients[strings.ToLower(host)] = newRetryableClient(opts, hostTLSConfig(cfg, tlsConfig, opts.Insecure))
 	}
 
+	destinations, err := newNamedDestinations(opts, tlsConfig)
+	if err != nil {
+		opts.Logger.Errorw("invalid named destinations, ignoring them",
+			"error", err,
+		)
+	}
+
+	return &DefaultForwarder{
+		rClient:             client,
+		hClient:             client.HTTPClient,
+		hostClients:         hostClients,
+		destinations:        destinations,
+		unixClients:         make(map[string]*retryablehttp.Client),
+		opts:                opts,
+		idempotencyHeaders:  opts.IdempotencyHeaders,
+		metadataHeaders:     opts.MetadataHeaders,
+		headerPolicy:        opts.HeaderPolicy,
+		maxResponseBody:     opts.MaxResponseBody,
+		discardResponseBody: opts.DiscardResponseBody,
+		responseRedactor:    opts.ResponseRedactor,
+		logger:              opts.Logger,
+	}
+}
+
+func newRetryableClient(opts *Opts, tlsConfig *tls.Config) *retryablehttp.Client {
+	tr := cleanhttp.DefaultPooledTransport()
+	if opts.Proxy != nil {
+		tr.Proxy = opts.Proxy
+	}
+	tr.TLSClientConfig = tlsConfig
+
+	client := retryablehttp.NewClient(opts.Logger)
+	client.HTTPClient = &http.Client{Transport: tr}
 	client.RetryMax = opts.Retries
+	if opts.MetadataHeaders {
+		client.RequestLogHook = func(_ *zap.SugaredLogger, req *http.Request, retry int) {
+			setAttemptHeader(req, retry)
+		}
+	}
 
-	return &DefaultForwarder{rClient: client, hClient: httpClient, logger: opts.Logger}
+	return client
+}
+
+// clientFor - returns client for the destination, host specific
+// clients are matched by host:port first and then by host
+func (r *DefaultForwarder) clientFor(u *url.URL) *retryablehttp.Client {
+	if len(r.hostClients) == 0 {
+		return r.rClient
+	}
+	if c, ok := r.hostClients[strings.ToLower(u.Host)]; ok {
+		return c
+	}
+	if c, ok := r.hostClients[strings.ToLower(u.Hostname())]; ok {
+		return c
+	}
+	return r.rClient
 }
 
 // Forward - relaying incoming webhook to original destination
@@ -73,23 +197,48 @@ func (r *DefaultForwarder) Forward(wh types.Event) (*types.LogUpdateRequest, err
 	if wh.RawQuery != "" {
 		wh.Meta.OutputDestination = wh.Meta.OutputDestination + "?" + wh.RawQuery
 	}
-	req, err := retryablehttp.NewRequest(wh.Method, wh.Meta.OutputDestination, bytes.NewReader([]byte(wh.Body)))
+	// passing text body as a string lets every attempt read the same
+	// memory instead of copying it into a rewindable buffer
+	var reqBody interface{} = wh.Body
+	if wh.BodyEncoding != types.BodyEncodingText {
+		raw, err := wh.RawBody()
+		if err != nil {
+			return &types.LogUpdateRequest{
+				ID:           wh.Meta.ID,
+				Status:       types.RequestStatusFailed,
+				ResponseBody: []byte(fmt.Sprintf("request failed, invalid webhook body: %s", err)),
+			}, nil
+		}
+		reqBody = raw
+	}
+	target, client, err := r.resolve(wh.Meta.OutputDestination)
+	if err != nil {
+		return &types.LogUpdateRequest{
+			ID:           wh.Meta.ID,
+			Status:       types.RequestStatusFailed,
+			ResponseBody: []byte(fmt.Sprintf("request failed, invalid destination: %s", err)),
+		}, nil
+	}
+	req, err := retryablehttp.NewRequest(wh.Method, target, reqBody)
 	if err != nil {
 		return nil, err
 	}
+	if client == nil {
+		client = r.clientFor(req.URL)
+	}
 
 	var retries int
 	var statusCode int
 
-	req.Header = wh.Headers
+	req.Header = r.requestHeaders(&wh)
 
-	resp, err := r.rClient.Do(req)
+	resp, err := client.Do(req)
 	if resp != nil {
 		retries = retryablehttp.GetRetries(resp)
 		statusCode = resp.StatusCode
 
 	}
-	if resp.Body != nil {
+	if resp != nil && resp.Body != nil {
 		defer resp.Body.Close()
 	}
 
@@ -103,7 +252,7 @@ func (r *DefaultForwarder) Forward(wh types.Event) (*types.LogUpdateRequest, err
 		}, nil
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := r.readResponseBody(resp.Body)
 	if err != nil {
 		return &types.LogUpdateRequest{
 			ID:              wh.Meta.ID,
@@ -111,7 +260,7 @@ func (r *DefaultForwarder) Forward(wh types.Event) (*types.LogUpdateRequest, err
 			Status:          types.RequestStatusFromCode(statusCode),
 			ResponseBody:    []byte(fmt.Sprintf("failed to read response body, error: %s", err)),
 			Retries:         retries,
-			ResponseHeaders: resp.Header,
+			ResponseHeaders: r.responseRedactor.Header(resp.Header),
 		}, nil
 	}
 
@@ -126,7 +275,57 @@ func (r *DefaultForwarder) Forward(wh types.Event) (*types.LogUpdateRequest, err
 		StatusCode:      resp.StatusCode,
 		ResponseBody:    body,
 		Status:          types.RequestStatusFromCode(statusCode),
-		ResponseHeaders: resp.Header,
+		ResponseHeaders: r.responseRedactor.Header(resp.Header),
 		Retries:         retries,
 	}, nil
 }
+
+// readResponseBody - reads response body that will be reported back to
+// Webhook Relay, applying size limit and redaction rules
+func (r *DefaultForwarder) readResponseBody(body io.Reader) ([]byte, error) {
+	if r.discardResponseBody {
+		// draining body so connection can be reused
+		_, err := io.Copy(ioutil.Discard, body)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(discardedMarker), nil
+	}
+
+	reader := body
+	if r.maxResponseBody > 0 {
+		reader = io.LimitReader(body, r.maxResponseBody+1)
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	_, err := buf.ReadFrom(reader)
+	if err != nil {
+		return nil, err
+	}
+	bts := buf.Bytes()
+
+	truncated := r.maxResponseBody > 0 && int64(len(bts)) > r.maxResponseBody
+	if truncated {
+		bts = bts[:r.maxResponseBody]
+		// draining up to the same amount again so that slightly larger
+		// responses don't close pooled connections
+		io.CopyN(ioutil.Discard, body, r.maxResponseBody)
+	}
+
+	redacted, ok := r.responseRedactor.Body(bts)
+	if !ok {
+		return []byte(withheldMarker), nil
+	}
+
+	var marker string
+	if truncated {
+		marker = fmt.Sprintf(truncatedMarker, r.maxResponseBody)
+	}
+
+	// buffer goes back to the pool, copying body out of it
+	out := make([]byte, len(redacted), len(redacted)+len(marker))
+	copy(out, redacted)
+	return append(out, marker...), nil
+}
@@ -1,15 +1,25 @@
 package forward
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
 
 	"net/http/httptest"
 	"testing"
 
+	"github.com/webhookrelay/relay-go/pkg/redact"
+	"github.com/webhookrelay/relay-go/pkg/retryablehttp"
 	"github.com/webhookrelay/relay-go/pkg/types"
 )
 
@@ -172,3 +182,286 @@ func TestRelayRetryTwice(t *testing.T) {
 		t.Errorf("unexpected amount of retries: %d", ws.Retries)
 	}
 }
+
+func TestRelayHostTLSConfig(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+
+	u, _ := url.Parse(ts.URL)
+
+	wr := types.Event{
+		Meta: types.EventMeta{
+			OutputDestination: ts.URL,
+		},
+		Method: http.MethodPost,
+		Body:   "hi",
+	}
+
+	// server certificate is not trusted by default
+	dr := NewDefaultForwarder(&Opts{Retries: 0})
+	ws, err := dr.Forward(wr)
+	assert.Nil(t, err)
+	if ws.Status != types.RequestStatusFailed {
+		t.Errorf("expected request to fail, got status: %s", ws.Status)
+	}
+
+	dr = NewDefaultForwarder(&Opts{
+		Retries: 0,
+		HostTLSConfigs: map[string]*tls.Config{
+			u.Host: {RootCAs: pool},
+		},
+	})
+	ws, err = dr.Forward(wr)
+	assert.Nil(t, err)
+	if ws.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status: %d", ws.StatusCode)
+	}
+
+	// insecure and minimum TLS version apply to host settings too
+	dr = NewDefaultForwarder(&Opts{
+		Retries:  0,
+		Insecure: true,
+		HostTLSConfigs: map[string]*tls.Config{
+			u.Host: {ServerName: "example.com"},
+		},
+	})
+	ws, err = dr.Forward(wr)
+	assert.Nil(t, err)
+	if ws.StatusCode != http.StatusOK {
+		t.Errorf("expected insecure request to succeed, got status: %d", ws.StatusCode)
+	}
+
+	dr = NewDefaultForwarder(&Opts{
+		Retries:   0,
+		TLSConfig: &tls.Config{MinVersion: tls.VersionTLS13},
+		HostTLSConfigs: map[string]*tls.Config{
+			u.Host: {RootCAs: pool, MaxVersion: tls.VersionTLS12},
+		},
+	})
+	ws, err = dr.Forward(wr)
+	assert.Nil(t, err)
+	if ws.Status != types.RequestStatusFailed {
+		t.Errorf("expected minimum TLS version to be enforced, got status: %d", ws.StatusCode)
+	}
+}
+
+func TestRelayIdempotencyHeaders(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header
+	}))
+	defer ts.Close()
+
+	dr := NewDefaultForwarder(&Opts{Retries: 0, IdempotencyHeaders: true})
+
+	wr := types.Event{
+		Meta: types.EventMeta{
+			ID:                "event-id",
+			OutputDestination: ts.URL,
+		},
+		Headers: map[string][]string{
+			"Idempotency-Key": {"from-sender"},
+		},
+		Method: http.MethodPost,
+	}
+
+	_, err := dr.Forward(wr)
+	assert.Nil(t, err)
+
+	h := <-headers
+	assert.Equal(t, "event-id", h.Get(HeaderWebhookID))
+	// sender's key is preserved
+	assert.Equal(t, "from-sender", h.Get(HeaderIdempotencyKey))
+	// event headers are not modified
+	assert.Equal(t, 1, len(wr.Headers))
+}
+
+func TestRelayResponseBodyLimitAndRedaction(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/large" {
+			fmt.Fprint(w, strings.Repeat("a", 100))
+			return
+		}
+		fmt.Fprint(w, `{"id":1,"user":{"token":"abc","name":"joe"}}`)
+	}))
+	defer ts.Close()
+
+	redactor, err := redact.New(&redact.Rules{
+		Headers: []string{"set-cookie"},
+		Fields:  []string{"token"},
+	})
+	assert.Nil(t, err)
+
+	dr := NewDefaultForwarder(&Opts{
+		MaxResponseBody:  64,
+		ResponseRedactor: redactor,
+	})
+
+	resp, err := dr.Forward(types.Event{
+		Meta:   types.EventMeta{ID: "1", OutputDestination: ts.URL + "/small"},
+		Method: http.MethodPost,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id":1,"user":{"name":"joe","token":"[REDACTED]"}}`, string(resp.ResponseBody))
+	assert.Equal(t, redact.Mask, resp.ResponseHeaders.Get("Set-Cookie"))
+	assert.Equal(t, "application/json", resp.ResponseHeaders.Get("Content-Type"))
+
+	resp, err = dr.Forward(types.Event{
+		Meta:   types.EventMeta{ID: "2", OutputDestination: ts.URL + "/large"},
+		Method: http.MethodPost,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, strings.Repeat("a", 64)+"\n...[truncated, response body exceeded 64 bytes]", string(resp.ResponseBody))
+}
+
+func TestRelayTruncatedJSONWithoutFieldRedaction(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":1,"items":["`+strings.Repeat("a", 100)+`"]}`)
+	}))
+	defer ts.Close()
+
+	// empty field names, as from an empty --redact-response-fields
+	// flag, don't enable field redaction
+	redactor, err := redact.New(&redact.Rules{
+		Headers: []string{"set-cookie"},
+		Fields:  []string{""},
+	})
+	assert.Nil(t, err)
+
+	dr := NewDefaultForwarder(&Opts{
+		MaxResponseBody:  32,
+		ResponseRedactor: redactor,
+	})
+
+	resp, err := dr.Forward(types.Event{
+		Meta:   types.EventMeta{ID: "1", OutputDestination: ts.URL},
+		Method: http.MethodPost,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id":1,"items":["aaaaaaaaaaaaaa`+"\n...[truncated, response body exceeded 32 bytes]", string(resp.ResponseBody))
+}
+
+func TestRelayDiscardResponseBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "private")
+	}))
+	defer ts.Close()
+
+	dr := NewDefaultForwarder(&Opts{DiscardResponseBody: true})
+
+	resp, err := dr.Forward(types.Event{
+		Meta:   types.EventMeta{ID: "1", OutputDestination: ts.URL},
+		Method: http.MethodPost,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NotContains(t, string(resp.ResponseBody), "private")
+}
+
+func benchmarkPayload() string {
+	return strings.Repeat("x", 20<<20)
+}
+
+// BenchmarkNewRequestBody compares building a rewindable request from a
+// copied byte slice with passing the event body string directly
+func BenchmarkNewRequestBody(b *testing.B) {
+	payload := benchmarkPayload()
+
+	b.Run("bytes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := retryablehttp.NewRequest(http.MethodPost, "http://localhost/", bytes.NewReader([]byte(payload)))
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("string", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := retryablehttp.NewRequest(http.MethodPost, "http://localhost/", payload)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkForwardLargeBody(b *testing.B) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(ioutil.Discard, r.Body)
+		w.Write([]byte(strings.Repeat("y", 64<<10)))
+	}))
+	defer ts.Close()
+
+	dr := NewDefaultForwarder(&Opts{Logger: zap.NewNop().Sugar()})
+	ev := types.Event{
+		Meta:   types.EventMeta{ID: "1", OutputDestination: ts.URL},
+		Method: http.MethodPost,
+		Body:   benchmarkPayload(),
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp, err := dr.Forward(ev)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			b.Fatalf("unexpected status: %d", resp.StatusCode)
+		}
+	}
+}
+
+func TestRelayBinaryBody(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(`{"hello":"world"}`))
+	w.Close()
+	payload := gz.Bytes()
+
+	var received []byte
+	var contentEncoding string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = ioutil.ReadAll(r.Body)
+		contentEncoding = r.Header.Get("Content-Encoding")
+	}))
+	defer ts.Close()
+
+	ev := types.Event{
+		Meta:    types.EventMeta{ID: "1", OutputDestination: ts.URL},
+		Method:  http.MethodPost,
+		Headers: map[string][]string{"Content-Encoding": {"gzip"}},
+	}
+	ev.SetRawBody(payload)
+	assert.Equal(t, types.BodyEncodingBase64, ev.BodyEncoding)
+
+	dr := NewDefaultForwarder(&Opts{})
+	resp, err := dr.Forward(ev)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, payload, received)
+	assert.Equal(t, "gzip", contentEncoding)
+}
+
+func TestRelayInvalidBodyEncoding(t *testing.T) {
+	dr := NewDefaultForwarder(&Opts{})
+	resp, err := dr.Forward(types.Event{
+		Meta:         types.EventMeta{ID: "1", OutputDestination: "http://localhost"},
+		Method:       http.MethodPost,
+		Body:         "not base64!",
+		BodyEncoding: types.BodyEncodingBase64,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, types.RequestStatusFailed, resp.Status)
+}
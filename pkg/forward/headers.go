@@ -0,0 +1,167 @@
+package forward
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// relay metadata headers, added when Opts.MetadataHeaders is set
+const (
+	HeaderRelayEventID    = "X-Relay-Event-Id"
+	HeaderRelayBucket     = "X-Relay-Bucket"
+	HeaderRelayBucketID   = "X-Relay-Bucket-Id"
+	HeaderRelayInput      = "X-Relay-Input"
+	HeaderRelayInputID    = "X-Relay-Input-Id"
+	HeaderRelayOutput     = "X-Relay-Output"
+	HeaderRelayAttempt    = "X-Relay-Attempt"
+	HeaderRelayReceivedAt = "X-Relay-Received-At"
+)
+
+// hopByHopHeaders - headers that only make sense for a single connection
+// and are always removed before forwarding
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+	"Host",
+	"Content-Length",
+}
+
+// HeaderPolicy - controls which incoming webhook headers reach destinations.
+// Hop-by-hop headers, Host and Content-Length are always removed. Names are
+// case insensitive and can end with '*' to match a prefix, e.g. X-Forwarded-*
+type HeaderPolicy struct {
+	// Allow - when set, only listed headers are forwarded
+	Allow []string
+	// Deny - headers that are never forwarded
+	Deny []string
+}
+
+// DefaultHeaderPolicy - removes headers that destinations could trust
+// to identify client address or relay metadata
+func DefaultHeaderPolicy() *HeaderPolicy {
+	return &HeaderPolicy{
+		Deny: []string{
+			"X-Forwarded-*",
+			"Forwarded",
+			"X-Real-Ip",
+			"X-Relay-*",
+		},
+	}
+}
+
+// allowed - checks whether header can be forwarded
+func (p *HeaderPolicy) allowed(name string) bool {
+	if p == nil {
+		return true
+	}
+	if matchHeader(p.Deny, name) {
+		return false
+	}
+	if len(p.Allow) > 0 {
+		return matchHeader(p.Allow, name)
+	}
+	return true
+}
+
+func matchHeader(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if strings.HasSuffix(p, "*") {
+			if len(name) >= len(p)-1 && strings.EqualFold(name[:len(p)-1], p[:len(p)-1]) {
+				return true
+			}
+			continue
+		}
+		if strings.EqualFold(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestHeaders - builds destination request headers from webhook headers,
+// event headers are not modified
+func (r *DefaultForwarder) requestHeaders(wh *types.Event) http.Header {
+	header := make(http.Header, len(wh.Headers)+2)
+
+	// headers listed in Connection are hop-by-hop too
+	var connectionHeaders []string
+	for k, v := range wh.Headers {
+		if strings.EqualFold(k, "Connection") {
+			for _, value := range v {
+				for _, name := range strings.Split(value, ",") {
+					connectionHeaders = append(connectionHeaders, strings.TrimSpace(name))
+				}
+			}
+		}
+	}
+
+	for k, v := range wh.Headers {
+		if matchHeader(hopByHopHeaders, k) || matchHeader(connectionHeaders, k) {
+			continue
+		}
+		if !r.headerPolicy.allowed(k) {
+			continue
+		}
+		header[http.CanonicalHeaderKey(k)] = v
+	}
+
+	if r.idempotencyHeaders && wh.Meta.ID != "" {
+		header.Set(HeaderWebhookID, wh.Meta.ID)
+		if header.Get(HeaderIdempotencyKey) == "" {
+			header.Set(HeaderIdempotencyKey, wh.Meta.ID)
+		}
+	}
+
+	if r.metadataHeaders {
+		setMetadataHeaders(header, &wh.Meta)
+	}
+
+	return header
+}
+
+func setMetadataHeaders(header http.Header, meta *types.EventMeta) {
+	set := func(name, value string) {
+		if value != "" {
+			header.Set(name, value)
+		}
+	}
+	set(HeaderRelayEventID, meta.ID)
+	set(HeaderRelayBucket, firstNonEmpty(meta.BucketName, meta.BucketID))
+	set(HeaderRelayBucketID, meta.BucketID)
+	set(HeaderRelayInput, firstNonEmpty(meta.InputName, meta.InputID))
+	set(HeaderRelayInputID, meta.InputID)
+	set(HeaderRelayOutput, meta.OutputName)
+	if !meta.ReceivedAt.IsZero() {
+		header.Set(HeaderRelayReceivedAt, meta.ReceivedAt.UTC().Format(time.RFC3339Nano))
+	}
+	// updated before each retry, see setAttemptHeader
+	header.Set(HeaderRelayAttempt, "1")
+}
+
+// setAttemptHeader - retryablehttp request hook that updates attempt
+// number on requests that carry relay metadata headers
+func setAttemptHeader(req *http.Request, retry int) {
+	if req.Header.Get(HeaderRelayAttempt) != "" {
+		req.Header.Set(HeaderRelayAttempt, strconv.Itoa(retry+1))
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
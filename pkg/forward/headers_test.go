@@ -0,0 +1,98 @@
+package forward
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+func TestHeaderPolicy(t *testing.T) {
+	p := &HeaderPolicy{
+		Allow: []string{"Content-Type", "X-Github-*"},
+		Deny:  []string{"X-Github-Secret"},
+	}
+
+	assert.True(t, p.allowed("content-type"))
+	assert.True(t, p.allowed("X-GitHub-Event"))
+	assert.False(t, p.allowed("X-Github-Secret"))
+	assert.False(t, p.allowed("Authorization"))
+
+	var nilPolicy *HeaderPolicy
+	assert.True(t, nilPolicy.allowed("Authorization"))
+}
+
+func TestRequestHeadersSanitized(t *testing.T) {
+	dr := NewDefaultForwarder(&Opts{HeaderPolicy: DefaultHeaderPolicy()})
+
+	h := dr.requestHeaders(&types.Event{
+		Headers: map[string][]string{
+			"Content-Type":      {"application/json"},
+			"Connection":        {"keep-alive, X-Hop"},
+			"X-Hop":             {"1"},
+			"Host":              {"example.com"},
+			"Content-Length":    {"10"},
+			"X-Forwarded-For":   {"10.0.0.1"},
+			"X-Relay-Bucket":    {"spoofed"},
+			"Transfer-Encoding": {"chunked"},
+		},
+	})
+
+	assert.Equal(t, http.Header{"Content-Type": {"application/json"}}, h)
+}
+
+func TestRelayMetadataHeaders(t *testing.T) {
+	received := make(chan http.Header, 2)
+	failed := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header
+		if !failed {
+			failed = true
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	dr := NewDefaultForwarder(&Opts{
+		Retries:         1,
+		MetadataHeaders: true,
+		HeaderPolicy:    DefaultHeaderPolicy(),
+	})
+
+	receivedAt := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	ws, err := dr.Forward(types.Event{
+		Meta: types.EventMeta{
+			ID:                "event-id",
+			BucketID:          "bucket-id",
+			BucketName:        "github",
+			InputID:           "input-id",
+			OutputName:        "local",
+			OutputDestination: ts.URL,
+			ReceivedAt:        receivedAt,
+		},
+		Headers: map[string][]string{
+			"X-Relay-Bucket": {"spoofed"},
+		},
+		Method: http.MethodPost,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, ws.StatusCode)
+
+	first := <-received
+	assert.Equal(t, "event-id", first.Get(HeaderRelayEventID))
+	assert.Equal(t, "github", first.Get(HeaderRelayBucket))
+	assert.Equal(t, "bucket-id", first.Get(HeaderRelayBucketID))
+	// input name is missing, falling back to ID
+	assert.Equal(t, "input-id", first.Get(HeaderRelayInput))
+	assert.Equal(t, "local", first.Get(HeaderRelayOutput))
+	assert.Equal(t, "2020-05-01T10:00:00Z", first.Get(HeaderRelayReceivedAt))
+	assert.Equal(t, "1", first.Get(HeaderRelayAttempt))
+
+	second := <-received
+	assert.Equal(t, "2", second.Get(HeaderRelayAttempt))
+}
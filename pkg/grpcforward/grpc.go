@@ -0,0 +1,378 @@
+// Package grpcforward delivers webhooks to gRPC services implementing
+// WebhookReceiver.Deliver from receiver.proto. Receivers are configured
+// with URLs:
+//
+//	grpc://host:9000?timeout=10s&retries=3   - plaintext HTTP/2 (h2c)
+//	grpcs://host:9443?insecure=true          - HTTP/2 over TLS
+//
+// Only unary calls without message compression are used, so the client
+// is built on net/http instead of the gRPC runtime.
+package grpcforward
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/webhookrelay/relay-go/pkg/forward"
+	"github.com/webhookrelay/relay-go/pkg/logger"
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// DeliverMethod - full name of the Deliver RPC
+const DeliverMethod = "/webhookrelay.receiver.v1.WebhookReceiver/Deliver"
+
+// DefaultTimeout - default deadline of a single Deliver call
+var DefaultTimeout = 10 * time.Second
+
+// maxMessageSize - largest accepted response message, same as
+// default gRPC receive limit
+const maxMessageSize = 4 << 20
+
+// Opts - gRPC forwarder configuration
+type Opts struct {
+	// Address - receiver host:port
+	Address string
+	// TLSConfig - TLS settings, plaintext HTTP/2 is used when nil
+	TLSConfig *tls.Config
+	// Timeout - deadline of a single Deliver call
+	Timeout time.Duration
+	// Retries - how many times calls failing with UNAVAILABLE or
+	// connection errors are retried
+	Retries int
+	Logger  *zap.SugaredLogger
+}
+
+var _ forward.Forwarder = &Forwarder{}
+
+// Forwarder - forwarder that calls WebhookReceiver.Deliver
+type Forwarder struct {
+	url     string
+	client  *http.Client
+	timeout time.Duration
+	retries int
+	logger  *zap.SugaredLogger
+}
+
+// NewForwarder - creates gRPC forwarder
+func NewForwarder(opts *Opts) (*Forwarder, error) {
+	if opts.Address == "" {
+		return nil, fmt.Errorf("address is required")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Retries < 0 {
+		return nil, fmt.Errorf("retries cannot be negative")
+	}
+	if opts.Logger == nil {
+		opts.Logger = logger.GetLoggerInstance(logger.DefaultLogLevel).Sugar()
+	}
+
+	tr := &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:   true,
+		TLSHandshakeTimeout: 10 * time.Second,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	scheme := "https"
+	if opts.TLSConfig != nil {
+		tr.TLSClientConfig = opts.TLSConfig.Clone()
+	} else {
+		scheme = "http"
+		if err := enableH2C(tr); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Forwarder{
+		url:     scheme + "://" + opts.Address + DeliverMethod,
+		client:  &http.Client{Transport: tr},
+		timeout: opts.Timeout,
+		retries: opts.Retries,
+		logger:  opts.Logger,
+	}, nil
+}
+
+// New - creates gRPC forwarder from grpc:// or grpcs:// URL, accepts
+// timeout, retries and insecure (skip TLS verification) parameters
+func New(u *url.URL, logger *zap.SugaredLogger) (*Forwarder, error) {
+	q := u.Query()
+	opts := &Opts{Logger: logger}
+
+	port := "80"
+	switch u.Scheme {
+	case "grpc":
+	case "grpcs":
+		port = "443"
+		opts.TLSConfig = &tls.Config{}
+		if v := q.Get("insecure"); v != "" {
+			insecure, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid insecure value '%s'", v)
+			}
+			opts.TLSConfig.InsecureSkipVerify = insecure
+		}
+	default:
+		return nil, fmt.Errorf("unknown scheme '%s', expected grpc or grpcs", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("receiver host is required")
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	opts.Address = net.JoinHostPort(u.Hostname(), port)
+
+	if t := q.Get("timeout"); t != "" {
+		var err error
+		opts.Timeout, err = time.ParseDuration(t)
+		if err != nil || opts.Timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout '%s'", t)
+		}
+	}
+	if r := q.Get("retries"); r != "" {
+		var err error
+		opts.Retries, err = strconv.Atoi(r)
+		if err != nil || opts.Retries < 0 {
+			return nil, fmt.Errorf("invalid retries '%s'", r)
+		}
+	}
+	return NewForwarder(opts)
+}
+
+// Forward - calls Deliver, response status is reported back to Webhook
+// Relay as HTTP status code and gRPC errors are mapped to HTTP codes
+func (f *Forwarder) Forward(wh types.Event) (*types.LogUpdateRequest, error) {
+	body, err := wh.RawBody()
+	if err != nil {
+		return &types.LogUpdateRequest{
+			ID:           wh.Meta.ID,
+			Status:       types.RequestStatusFailed,
+			ResponseBody: []byte(fmt.Sprintf("request failed, invalid webhook body: %s", err)),
+		}, nil
+	}
+	msg := frame(newDeliverRequest(&wh, body).Marshal())
+
+	var (
+		resp    *DeliverResponse
+		retries int
+	)
+	for {
+		resp, err = f.deliver(msg)
+		if err == nil || retries >= f.retries || !retryable(err) {
+			break
+		}
+		retries++
+		f.logger.Debugw("retrying gRPC delivery",
+			"id", wh.Meta.ID,
+			"retry", retries,
+			"error", err,
+		)
+		time.Sleep(backoff(retries))
+	}
+
+	if err != nil {
+		f.logger.Warnw("gRPC delivery failed",
+			"id", wh.Meta.ID,
+			"address", f.url,
+			"error", err,
+		)
+		var se *statusError
+		if errors.As(err, &se) {
+			code := se.httpStatus()
+			return &types.LogUpdateRequest{
+				ID:           wh.Meta.ID,
+				StatusCode:   code,
+				Status:       types.RequestStatusFromCode(code),
+				ResponseBody: []byte(fmt.Sprintf("gRPC error: %s", se)),
+				Retries:      retries,
+			}, nil
+		}
+		return &types.LogUpdateRequest{
+			ID:           wh.Meta.ID,
+			Status:       types.RequestStatusFailed,
+			ResponseBody: []byte(fmt.Sprintf("request failed, gRPC client error: %s", err)),
+			Retries:      retries,
+		}, nil
+	}
+
+	code := int(resp.Status)
+	if code == 0 {
+		code = http.StatusOK
+	}
+	f.logger.Infow("webhook delivered",
+		"status_code", code,
+		"address", f.url,
+	)
+	return &types.LogUpdateRequest{
+		ID:              wh.Meta.ID,
+		StatusCode:      code,
+		Status:          types.RequestStatusFromCode(code),
+		ResponseBody:    resp.Body,
+		ResponseHeaders: toHTTPHeader(resp.Headers),
+		Retries:         retries,
+	}, nil
+}
+
+// deliver - makes a single unary call
+func (f *Forwarder) deliver(msg []byte) (*DeliverResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.url, bytes.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/grpc+proto")
+	req.Header.Set("TE", "trailers")
+	req.Header.Set("Grpc-Timeout", strconv.FormatInt(int64(f.timeout/time.Millisecond), 10)+"m")
+	req.Header.Set("User-Agent", "relayd-grpc")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.ProtoMajor != 2 {
+		return nil, fmt.Errorf("receiver responded with %s, gRPC requires HTTP/2", resp.Proto)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{code: codeFromHTTP(resp.StatusCode), message: resp.Status}
+	}
+	// trailers-only responses carry status in headers
+	if status := resp.Header.Get("Grpc-Status"); status != "" {
+		return nil, parseStatus(status, resp.Header.Get("Grpc-Message"))
+	}
+
+	data, readErr := readMessage(resp.Body)
+	// trailers are available once body is read to the end
+	io.Copy(ioutil.Discard, resp.Body)
+
+	status := resp.Trailer.Get("Grpc-Status")
+	if status == "" {
+		if readErr != nil {
+			return nil, readErr
+		}
+		return nil, fmt.Errorf("receiver response is missing grpc-status")
+	}
+	if err := parseStatus(status, resp.Trailer.Get("Grpc-Message")); err != nil {
+		return nil, err
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	var out DeliverResponse
+	if err := out.Unmarshal(data); err != nil {
+		return nil, fmt.Errorf("invalid response message: %s", err)
+	}
+	return &out, nil
+}
+
+func newDeliverRequest(wh *types.Event, body []byte) *DeliverRequest {
+	names := make([]string, 0, len(wh.Headers))
+	for name := range wh.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	headers := make([]*Header, 0, len(names))
+	for _, name := range names {
+		headers = append(headers, &Header{Name: name, Values: wh.Headers[name]})
+	}
+
+	return &DeliverRequest{
+		Meta: &Meta{
+			ID:                wh.Meta.ID,
+			BucketID:          wh.Meta.BucketID,
+			BucketName:        wh.Meta.BucketName,
+			InputID:           wh.Meta.InputID,
+			InputName:         wh.Meta.InputName,
+			OutputName:        wh.Meta.OutputName,
+			OutputDestination: wh.Meta.OutputDestination,
+			ReceivedAt:        wh.Meta.ReceivedAt,
+		},
+		Method:  wh.Method,
+		Query:   wh.RawQuery,
+		Headers: headers,
+		Body:    body,
+	}
+}
+
+func toHTTPHeader(headers []*Header) http.Header {
+	if len(headers) == 0 {
+		return nil
+	}
+	out := make(http.Header, len(headers))
+	for _, h := range headers {
+		name := http.CanonicalHeaderKey(h.Name)
+		out[name] = append(out[name], h.Values...)
+	}
+	return out
+}
+
+// frame - prefixes message with gRPC length-prefixed message header,
+// compression flag is always 0
+func frame(msg []byte) []byte {
+	out := make([]byte, 5+len(msg))
+	binary.BigEndian.PutUint32(out[1:5], uint32(len(msg)))
+	copy(out[5:], msg)
+	return out
+}
+
+// readMessage - reads single length-prefixed message
+func readMessage(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("receiver returned no response message")
+		}
+		return nil, err
+	}
+	if prefix[0] != 0 {
+		return nil, fmt.Errorf("compressed response messages are not supported")
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > maxMessageSize {
+		return nil, fmt.Errorf("response message of %d bytes exceeds %d bytes limit", size, maxMessageSize)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func retryable(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code == codeUnavailable
+	}
+	// connection errors
+	return true
+}
+
+func backoff(attempt int) time.Duration {
+	d := 100 * time.Millisecond << uint(attempt-1)
+	if d > 5*time.Second {
+		d = 5 * time.Second
+	}
+	return d
+}
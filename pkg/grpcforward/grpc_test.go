@@ -0,0 +1,249 @@
+package grpcforward
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// receiverFunc - Deliver implementation, non-zero code is
+// returned as gRPC status with message
+type receiverFunc func(req *DeliverRequest) (resp *DeliverResponse, code int, message string)
+
+// receiverHandler - in-process WebhookReceiver server
+func receiverHandler(t *testing.T, fn receiverFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.URL.Path != DeliverMethod || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			t.Errorf("unexpected request: %s %s %s", r.Proto, r.URL.Path, r.Header.Get("Content-Type"))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, err := readMessage(r.Body)
+		if err != nil {
+			t.Errorf("failed to read request: %s", err)
+			return
+		}
+		var req DeliverRequest
+		if err := req.Unmarshal(data); err != nil {
+			t.Errorf("failed to decode request: %s", err)
+			return
+		}
+
+		resp, code, message := fn(&req)
+		w.Header().Set("Content-Type", "application/grpc")
+		w.WriteHeader(http.StatusOK)
+		if code == codeOK {
+			w.Write(frame(resp.Marshal()))
+		}
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", url.PathEscape(message))
+	})
+}
+
+// newTLSReceiver - starts receiver over HTTP/2 with TLS and returns
+// forwarder that trusts it
+func newTLSReceiver(t *testing.T, retries int, fn receiverFunc) (*Forwarder, func()) {
+	ts := httptest.NewUnstartedServer(receiverHandler(t, fn))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	f, err := NewForwarder(&Opts{
+		Address:   strings.TrimPrefix(ts.URL, "https://"),
+		TLSConfig: &tls.Config{RootCAs: pool},
+		Timeout:   5 * time.Second,
+		Retries:   retries,
+		Logger:    zap.NewNop().Sugar(),
+	})
+	if err != nil {
+		ts.Close()
+		t.Fatalf("failed to create forwarder: %s", err)
+	}
+	return f, ts.Close
+}
+
+func TestDeliver(t *testing.T) {
+	received := time.Date(2020, 1, 2, 15, 4, 5, 6, time.UTC)
+
+	f, stop := newTLSReceiver(t, 0, func(req *DeliverRequest) (*DeliverResponse, int, string) {
+		if req.Meta.ID != "wh-1" || req.Meta.BucketName != "github" || !req.Meta.ReceivedAt.Equal(received) {
+			t.Errorf("unexpected meta: %+v", req.Meta)
+		}
+		if req.Method != http.MethodPost || req.Query != "a=b" {
+			t.Errorf("unexpected method and query: %s %s", req.Method, req.Query)
+		}
+		if len(req.Headers) != 2 || req.Headers[0].Name != "Content-Type" || req.Headers[1].Values[1] != "b" {
+			t.Errorf("unexpected headers: %+v", req.Headers)
+		}
+		if string(req.Body) != "\x00\x01binary" {
+			t.Errorf("unexpected body: %q", req.Body)
+		}
+		return &DeliverResponse{
+			Status:  http.StatusAccepted,
+			Headers: []*Header{{Name: "x-receipt", Values: []string{"r-1"}}},
+			Body:    []byte("queued"),
+		}, codeOK, ""
+	})
+	defer stop()
+
+	result, err := f.Forward(types.Event{
+		Meta: types.EventMeta{
+			ID:         "wh-1",
+			BucketName: "github",
+			ReceivedAt: received,
+		},
+		Headers: map[string][]string{
+			"X-Multi":      {"a", "b"},
+			"Content-Type": {"application/octet-stream"},
+		},
+		RawQuery:     "a=b",
+		Method:       http.MethodPost,
+		Body:         "AAFiaW5hcnk=",
+		BodyEncoding: types.BodyEncodingBase64,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.ID != "wh-1" || result.StatusCode != http.StatusAccepted || result.Status != types.RequestStatusSent {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if string(result.ResponseBody) != "queued" || result.ResponseHeaders.Get("X-Receipt") != "r-1" {
+		t.Errorf("unexpected response: %s %v", result.ResponseBody, result.ResponseHeaders)
+	}
+}
+
+func TestDeliverStatusDefaultsToOK(t *testing.T) {
+	f, stop := newTLSReceiver(t, 0, func(req *DeliverRequest) (*DeliverResponse, int, string) {
+		return &DeliverResponse{}, codeOK, ""
+	})
+	defer stop()
+
+	result, err := f.Forward(types.Event{Meta: types.EventMeta{ID: "wh-1"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.StatusCode != http.StatusOK || result.Status != types.RequestStatusSent {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestDeliverErrors(t *testing.T) {
+	var calls int32
+	f, stop := newTLSReceiver(t, 2, func(req *DeliverRequest) (*DeliverResponse, int, string) {
+		atomic.AddInt32(&calls, 1)
+		if req.Meta.ID == "unavailable" {
+			return nil, codeUnavailable, "receiver is restarting"
+		}
+		return nil, codeInvalidArgument, "unknown event type: 100%"
+	})
+	defer stop()
+
+	result, err := f.Forward(types.Event{Meta: types.EventMeta{ID: "unavailable"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.StatusCode != http.StatusServiceUnavailable || result.Status != types.RequestStatusFailed || result.Retries != 2 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if string(result.ResponseBody) != "gRPC error: UNAVAILABLE: receiver is restarting" {
+		t.Errorf("unexpected body: %s", result.ResponseBody)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("expected 3 calls, got: %d", n)
+	}
+
+	// invalid arguments are not retried
+	atomic.StoreInt32(&calls, 0)
+	result, err = f.Forward(types.Event{Meta: types.EventMeta{ID: "invalid"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.StatusCode != http.StatusBadRequest || result.Retries != 0 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if string(result.ResponseBody) != "gRPC error: INVALID_ARGUMENT: unknown event type: 100%" {
+		t.Errorf("unexpected body: %s", result.ResponseBody)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected 1 call, got: %d", n)
+	}
+}
+
+func TestDeliverConnectionError(t *testing.T) {
+	f, stop := newTLSReceiver(t, 0, nil)
+	stop()
+
+	result, err := f.Forward(types.Event{Meta: types.EventMeta{ID: "wh-1"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.StatusCode != 0 || result.Status != types.RequestStatusFailed {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if !strings.HasPrefix(string(result.ResponseBody), "request failed, gRPC client error") {
+		t.Errorf("unexpected body: %s", result.ResponseBody)
+	}
+}
+
+func TestMessagesSkipUnknownFields(t *testing.T) {
+	resp := &DeliverResponse{
+		Status:  -1,
+		Headers: []*Header{{Name: "a", Values: []string{"", "b"}}},
+		Body:    []byte("body"),
+	}
+	data := resp.Marshal()
+	// fields added in newer receiver versions
+	data = appendString(data, 20, "new")
+	data = appendTag(data, 21, wireVarint)
+	data = appendVarint(data, 300)
+	data = appendTag(data, 22, wireFixed64)
+	data = append(data, make([]byte, 8)...)
+
+	var out DeliverResponse
+	if err := out.Unmarshal(data); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if out.Status != -1 || string(out.Body) != "body" || len(out.Headers[0].Values) != 2 {
+		t.Errorf("unexpected response: %+v", out)
+	}
+
+	if err := out.Unmarshal(data[:len(data)-3]); err == nil {
+		t.Errorf("expected truncated message to be rejected")
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, valid := range []string{
+		"grpc://receiver:9000",
+		"grpcs://receiver?insecure=true&timeout=5s&retries=3",
+	} {
+		u, _ := url.Parse(valid)
+		if _, err := New(u, zap.NewNop().Sugar()); err != nil {
+			t.Errorf("%s: unexpected error: %s", valid, err)
+		}
+	}
+	for _, invalid := range []string{
+		"grpc://",
+		"grpcs://receiver?insecure=maybe",
+		"grpc://receiver?timeout=-1s",
+		"grpc://receiver?retries=-1",
+		"http://receiver",
+	} {
+		u, _ := url.Parse(invalid)
+		if _, err := New(u, zap.NewNop().Sugar()); err == nil {
+			t.Errorf("%s: expected an error", invalid)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build go1.24
+// +build go1.24
+
+package grpcforward
+
+import "net/http"
+
+// enableH2C - plaintext receivers are called over HTTP/2 with prior
+// knowledge, as gRPC clients do
+func enableH2C(tr *http.Transport) error {
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	tr.Protocols = protocols
+	return nil
+}
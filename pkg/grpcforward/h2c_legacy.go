@@ -0,0 +1,14 @@
+//go:build !go1.24
+// +build !go1.24
+
+package grpcforward
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// enableH2C - net/http supports plaintext HTTP/2 since Go 1.24
+func enableH2C(tr *http.Transport) error {
+	return fmt.Errorf("plaintext gRPC requires relayd built with Go 1.24 or newer, use grpcs:// receivers")
+}
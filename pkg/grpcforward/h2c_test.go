@@ -0,0 +1,41 @@
+//go:build go1.24
+// +build go1.24
+
+package grpcforward
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+func TestDeliverPlaintext(t *testing.T) {
+	ts := httptest.NewUnstartedServer(receiverHandler(t, func(req *DeliverRequest) (*DeliverResponse, int, string) {
+		return &DeliverResponse{Body: []byte("hello " + req.Meta.BucketName)}, codeOK, ""
+	}))
+	ts.Config.Protocols = new(http.Protocols)
+	ts.Config.Protocols.SetUnencryptedHTTP2(true)
+	ts.Start()
+	defer ts.Close()
+
+	f, err := NewForwarder(&Opts{
+		Address: strings.TrimPrefix(ts.URL, "http://"),
+		Logger:  zap.NewNop().Sugar(),
+	})
+	if err != nil {
+		t.Fatalf("failed to create forwarder: %s", err)
+	}
+
+	result, err := f.Forward(types.Event{Meta: types.EventMeta{ID: "wh-1", BucketName: "github"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.StatusCode != http.StatusOK || string(result.ResponseBody) != "hello github" {
+		t.Errorf("unexpected result: %+v, body: %s", result, result.ResponseBody)
+	}
+}
@@ -0,0 +1,410 @@
+package grpcforward
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// messages from receiver.proto, encoded by hand so relayd doesn't need
+// protobuf runtime. Only wire types used by receiver.proto are written,
+// unknown fields are skipped when decoding
+
+// protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("truncated message")
+
+// Meta - webhook metadata, see types.EventMeta
+type Meta struct {
+	ID                string
+	BucketID          string
+	BucketName        string
+	InputID           string
+	InputName         string
+	OutputName        string
+	OutputDestination string
+	ReceivedAt        time.Time
+}
+
+// Header - header name with its values
+type Header struct {
+	Name   string
+	Values []string
+}
+
+// DeliverRequest - WebhookReceiver.Deliver request
+type DeliverRequest struct {
+	Meta    *Meta
+	Method  string
+	Query   string
+	Headers []*Header
+	Body    []byte
+}
+
+// DeliverResponse - WebhookReceiver.Deliver response
+type DeliverResponse struct {
+	// Status - HTTP style status code, 0 means 200
+	Status  int32
+	Headers []*Header
+	Body    []byte
+}
+
+// Marshal - encodes request in protobuf wire format
+func (m *DeliverRequest) Marshal() []byte {
+	var b []byte
+	if m.Meta != nil {
+		b = appendMessage(b, 1, m.Meta.marshal())
+	}
+	b = appendString(b, 2, m.Method)
+	b = appendString(b, 3, m.Query)
+	for _, h := range m.Headers {
+		b = appendMessage(b, 4, h.marshal())
+	}
+	b = appendBytes(b, 5, m.Body)
+	return b
+}
+
+// Unmarshal - decodes request from protobuf wire format
+func (m *DeliverRequest) Unmarshal(data []byte) error {
+	d := &decoder{b: data}
+	for !d.done() {
+		num, wt, err := d.tag()
+		if err != nil {
+			return err
+		}
+		if wt != wireBytes {
+			if err := d.skip(wt); err != nil {
+				return err
+			}
+			continue
+		}
+		v, err := d.bytes()
+		if err != nil {
+			return err
+		}
+		switch num {
+		case 1:
+			m.Meta = &Meta{}
+			err = m.Meta.unmarshal(v)
+		case 2:
+			m.Method = string(v)
+		case 3:
+			m.Query = string(v)
+		case 4:
+			h := &Header{}
+			err = h.unmarshal(v)
+			m.Headers = append(m.Headers, h)
+		case 5:
+			m.Body = append([]byte{}, v...)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Marshal - encodes response in protobuf wire format
+func (m *DeliverResponse) Marshal() []byte {
+	var b []byte
+	if m.Status != 0 {
+		b = appendTag(b, 1, wireVarint)
+		b = appendVarint(b, uint64(int64(m.Status)))
+	}
+	for _, h := range m.Headers {
+		b = appendMessage(b, 2, h.marshal())
+	}
+	b = appendBytes(b, 3, m.Body)
+	return b
+}
+
+// Unmarshal - decodes response from protobuf wire format
+func (m *DeliverResponse) Unmarshal(data []byte) error {
+	d := &decoder{b: data}
+	for !d.done() {
+		num, wt, err := d.tag()
+		if err != nil {
+			return err
+		}
+		switch {
+		case num == 1 && wt == wireVarint:
+			v, err := d.varint()
+			if err != nil {
+				return err
+			}
+			m.Status = int32(v)
+		case num == 2 && wt == wireBytes:
+			v, err := d.bytes()
+			if err != nil {
+				return err
+			}
+			h := &Header{}
+			if err := h.unmarshal(v); err != nil {
+				return err
+			}
+			m.Headers = append(m.Headers, h)
+		case num == 3 && wt == wireBytes:
+			v, err := d.bytes()
+			if err != nil {
+				return err
+			}
+			m.Body = append([]byte{}, v...)
+		default:
+			if err := d.skip(wt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Meta) marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, m.ID)
+	b = appendString(b, 2, m.BucketID)
+	b = appendString(b, 3, m.BucketName)
+	b = appendString(b, 4, m.InputID)
+	b = appendString(b, 5, m.InputName)
+	b = appendString(b, 6, m.OutputName)
+	b = appendString(b, 7, m.OutputDestination)
+	if !m.ReceivedAt.IsZero() {
+		// google.protobuf.Timestamp
+		var ts []byte
+		if s := m.ReceivedAt.Unix(); s != 0 {
+			ts = appendTag(ts, 1, wireVarint)
+			ts = appendVarint(ts, uint64(s))
+		}
+		if n := m.ReceivedAt.Nanosecond(); n != 0 {
+			ts = appendTag(ts, 2, wireVarint)
+			ts = appendVarint(ts, uint64(n))
+		}
+		b = appendMessage(b, 8, ts)
+	}
+	return b
+}
+
+func (m *Meta) unmarshal(data []byte) error {
+	d := &decoder{b: data}
+	for !d.done() {
+		num, wt, err := d.tag()
+		if err != nil {
+			return err
+		}
+		if wt != wireBytes {
+			if err := d.skip(wt); err != nil {
+				return err
+			}
+			continue
+		}
+		v, err := d.bytes()
+		if err != nil {
+			return err
+		}
+		switch num {
+		case 1:
+			m.ID = string(v)
+		case 2:
+			m.BucketID = string(v)
+		case 3:
+			m.BucketName = string(v)
+		case 4:
+			m.InputID = string(v)
+		case 5:
+			m.InputName = string(v)
+		case 6:
+			m.OutputName = string(v)
+		case 7:
+			m.OutputDestination = string(v)
+		case 8:
+			m.ReceivedAt, err = unmarshalTimestamp(v)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func unmarshalTimestamp(data []byte) (time.Time, error) {
+	var seconds, nanos int64
+	d := &decoder{b: data}
+	for !d.done() {
+		num, wt, err := d.tag()
+		if err != nil {
+			return time.Time{}, err
+		}
+		if wt != wireVarint {
+			if err := d.skip(wt); err != nil {
+				return time.Time{}, err
+			}
+			continue
+		}
+		v, err := d.varint()
+		if err != nil {
+			return time.Time{}, err
+		}
+		switch num {
+		case 1:
+			seconds = int64(v)
+		case 2:
+			nanos = int64(int32(v))
+		}
+	}
+	return time.Unix(seconds, nanos).UTC(), nil
+}
+
+func (h *Header) marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, h.Name)
+	for _, v := range h.Values {
+		// repeated fields keep empty values
+		b = appendTag(b, 2, wireBytes)
+		b = appendVarint(b, uint64(len(v)))
+		b = append(b, v...)
+	}
+	return b
+}
+
+func (h *Header) unmarshal(data []byte) error {
+	d := &decoder{b: data}
+	for !d.done() {
+		num, wt, err := d.tag()
+		if err != nil {
+			return err
+		}
+		if wt != wireBytes {
+			if err := d.skip(wt); err != nil {
+				return err
+			}
+			continue
+		}
+		v, err := d.bytes()
+		if err != nil {
+			return err
+		}
+		switch num {
+		case 1:
+			h.Name = string(v)
+		case 2:
+			h.Values = append(h.Values, string(v))
+		}
+	}
+	return nil
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendTag(b []byte, num, wireType int) []byte {
+	return appendVarint(b, uint64(num)<<3|uint64(wireType))
+}
+
+// appendString - appends string field, proto3 omits empty values
+func appendString(b []byte, num int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = appendTag(b, num, wireBytes)
+	b = appendVarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendBytes(b []byte, num int, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = appendTag(b, num, wireBytes)
+	b = appendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// appendMessage - appends embedded message, present even when empty
+func appendMessage(b []byte, num int, msg []byte) []byte {
+	b = appendTag(b, num, wireBytes)
+	b = appendVarint(b, uint64(len(msg)))
+	return append(b, msg...)
+}
+
+// decoder - reads protobuf wire format
+type decoder struct {
+	b []byte
+}
+
+func (d *decoder) done() bool {
+	return len(d.b) == 0
+}
+
+func (d *decoder) varint() (uint64, error) {
+	var v uint64
+	for i := 0; i < 10; i++ {
+		if i >= len(d.b) {
+			return 0, errTruncated
+		}
+		c := d.b[i]
+		v |= uint64(c&0x7f) << (7 * uint(i))
+		if c < 0x80 {
+			d.b = d.b[i+1:]
+			return v, nil
+		}
+	}
+	return 0, errors.New("varint overflow")
+}
+
+func (d *decoder) tag() (int, int, error) {
+	v, err := d.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+	num, wt := int(v>>3), int(v&7)
+	if num <= 0 {
+		return 0, 0, fmt.Errorf("invalid field number %d", num)
+	}
+	return num, wt, nil
+}
+
+func (d *decoder) bytes() ([]byte, error) {
+	n, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.b)) {
+		return nil, errTruncated
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v, nil
+}
+
+func (d *decoder) skip(wireType int) error {
+	var n int
+	switch wireType {
+	case wireVarint:
+		_, err := d.varint()
+		return err
+	case wireBytes:
+		_, err := d.bytes()
+		return err
+	case wireFixed64:
+		n = 8
+	case wireFixed32:
+		n = 4
+	default:
+		return fmt.Errorf("unsupported wire type %d", wireType)
+	}
+	if len(d.b) < n {
+		return errTruncated
+	}
+	d.b = d.b[n:]
+	return nil
+}
@@ -0,0 +1,120 @@
+package grpcforward
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// golden messages in the canonical encoding of receiver.proto, fields in
+// field number order as protoc generated code writes them. They can be
+// checked with:
+//
+//	printf '<bytes>' | protoc --decode=webhookrelay.receiver.v1.DeliverRequest receiver.proto
+//
+// Each line is one field: tag (field number << 3 | wire type), length
+// for length delimited fields and value
+var (
+	goldenRequest = "" +
+		"\x0a\x3f" + // 1: meta, 63 bytes
+		"\x0a\x03" + "ev1" + // meta 1: id
+		"\x12\x02" + "b1" + // meta 2: bucket_id
+		"\x1a\x06" + "github" + // meta 3: bucket_name
+		"\x22\x02" + "i1" + // meta 4: input_id
+		"\x2a\x06" + "public" + // meta 5: input_name
+		"\x32\x02" + "ci" + // meta 6: output_name
+		"\x3a\x0e" + "http://ci/hook" + // meta 7: output_destination
+		"\x42\x0c" + // meta 8: received_at, google.protobuf.Timestamp
+		"\x08\xe5\x8c\xb8\xf0\x05" + // timestamp 1: seconds 1577977445 (varint)
+		"\x10\x80\xca\xb5\xee\x01" + // timestamp 2: nanos 500000000 (varint)
+		"\x12\x04" + "POST" + // 2: method
+		"\x1a\x03" + "x=1" + // 3: query
+		"\x22\x20" + // 4: headers, 32 bytes
+		"\x0a\x0c" + "Content-Type" + // header 1: name
+		"\x12\x10" + "application/json" + // header 2: values
+		"\x22\x0e" + // 4: headers, 14 bytes
+		"\x0a\x07" + "X-Multi" + // header 1: name
+		"\x12\x01" + "a" + // header 2: values
+		"\x12\x00" + // header 2: values, empty value is kept
+		"\x2a\x02" + "\x00\xff" // 5: body
+
+	goldenResponse = "" +
+		"\x08\xc9\x01" + // 1: status 201 (varint)
+		"\x12\x0e" + // 2: headers, 14 bytes
+		"\x0a\x08" + "Location" + // header 1: name
+		"\x12\x02" + "/x" + // header 2: values
+		"\x1a\x02" + "ok" // 3: body
+
+	// negative int32 values are sign extended to 10 byte varints
+	goldenNegativeStatus = "\x08\xff\xff\xff\xff\xff\xff\xff\xff\xff\x01"
+)
+
+func goldenDeliverRequest() *DeliverRequest {
+	return &DeliverRequest{
+		Meta: &Meta{
+			ID:                "ev1",
+			BucketID:          "b1",
+			BucketName:        "github",
+			InputID:           "i1",
+			InputName:         "public",
+			OutputName:        "ci",
+			OutputDestination: "http://ci/hook",
+			ReceivedAt:        time.Date(2020, 1, 2, 15, 4, 5, 500000000, time.UTC),
+		},
+		Method: "POST",
+		Query:  "x=1",
+		Headers: []*Header{
+			{Name: "Content-Type", Values: []string{"application/json"}},
+			{Name: "X-Multi", Values: []string{"a", ""}},
+		},
+		Body: []byte{0x00, 0xff},
+	}
+}
+
+func TestDeliverRequestGolden(t *testing.T) {
+	req := goldenDeliverRequest()
+	if got := req.Marshal(); !bytes.Equal(got, []byte(goldenRequest)) {
+		t.Errorf("unexpected encoding:\n got %x\nwant %x", got, goldenRequest)
+	}
+
+	var decoded DeliverRequest
+	if err := decoded.Unmarshal([]byte(goldenRequest)); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	if !reflect.DeepEqual(&decoded, req) {
+		t.Errorf("unexpected request: %+v, meta: %+v", decoded, decoded.Meta)
+	}
+}
+
+func TestDeliverResponseGolden(t *testing.T) {
+	cases := []struct {
+		encoded string
+		resp    *DeliverResponse
+	}{
+		{
+			encoded: goldenResponse,
+			resp: &DeliverResponse{
+				Status:  201,
+				Headers: []*Header{{Name: "Location", Values: []string{"/x"}}},
+				Body:    []byte("ok"),
+			},
+		},
+		{encoded: goldenNegativeStatus, resp: &DeliverResponse{Status: -1}},
+		{encoded: "", resp: &DeliverResponse{}},
+	}
+	for _, c := range cases {
+		if got := c.resp.Marshal(); !bytes.Equal(got, []byte(c.encoded)) {
+			t.Errorf("unexpected encoding:\n got %x\nwant %x", got, c.encoded)
+		}
+
+		var decoded DeliverResponse
+		if err := decoded.Unmarshal([]byte(c.encoded)); err != nil {
+			t.Errorf("%x: failed to decode: %s", c.encoded, err)
+			continue
+		}
+		if !reflect.DeepEqual(&decoded, c.resp) {
+			t.Errorf("%x: unexpected response: %+v", c.encoded, decoded)
+		}
+	}
+}
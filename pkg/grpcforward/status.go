@@ -0,0 +1,129 @@
+package grpcforward
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// gRPC status codes
+const (
+	codeOK                 = 0
+	codeCanceled           = 1
+	codeUnknown            = 2
+	codeInvalidArgument    = 3
+	codeDeadlineExceeded   = 4
+	codeNotFound           = 5
+	codeAlreadyExists      = 6
+	codePermissionDenied   = 7
+	codeResourceExhausted  = 8
+	codeFailedPrecondition = 9
+	codeAborted            = 10
+	codeOutOfRange         = 11
+	codeUnimplemented      = 12
+	codeInternal           = 13
+	codeUnavailable        = 14
+	codeDataLoss           = 15
+	codeUnauthenticated    = 16
+)
+
+var codeNames = map[int]string{
+	codeOK:                 "OK",
+	codeCanceled:           "CANCELLED",
+	codeUnknown:            "UNKNOWN",
+	codeInvalidArgument:    "INVALID_ARGUMENT",
+	codeDeadlineExceeded:   "DEADLINE_EXCEEDED",
+	codeNotFound:           "NOT_FOUND",
+	codeAlreadyExists:      "ALREADY_EXISTS",
+	codePermissionDenied:   "PERMISSION_DENIED",
+	codeResourceExhausted:  "RESOURCE_EXHAUSTED",
+	codeFailedPrecondition: "FAILED_PRECONDITION",
+	codeAborted:            "ABORTED",
+	codeOutOfRange:         "OUT_OF_RANGE",
+	codeUnimplemented:      "UNIMPLEMENTED",
+	codeInternal:           "INTERNAL",
+	codeUnavailable:        "UNAVAILABLE",
+	codeDataLoss:           "DATA_LOSS",
+	codeUnauthenticated:    "UNAUTHENTICATED",
+}
+
+// HTTP status codes reported for gRPC errors, same mapping
+// as used by gRPC gateways
+var httpStatuses = map[int]int{
+	codeCanceled:           499,
+	codeUnknown:            http.StatusInternalServerError,
+	codeInvalidArgument:    http.StatusBadRequest,
+	codeDeadlineExceeded:   http.StatusGatewayTimeout,
+	codeNotFound:           http.StatusNotFound,
+	codeAlreadyExists:      http.StatusConflict,
+	codePermissionDenied:   http.StatusForbidden,
+	codeResourceExhausted:  http.StatusTooManyRequests,
+	codeFailedPrecondition: http.StatusBadRequest,
+	codeAborted:            http.StatusConflict,
+	codeOutOfRange:         http.StatusBadRequest,
+	codeUnimplemented:      http.StatusNotImplemented,
+	codeInternal:           http.StatusInternalServerError,
+	codeUnavailable:        http.StatusServiceUnavailable,
+	codeDataLoss:           http.StatusInternalServerError,
+	codeUnauthenticated:    http.StatusUnauthorized,
+}
+
+// statusError - call failed with non-OK gRPC status
+type statusError struct {
+	code    int
+	message string
+}
+
+func (e *statusError) Error() string {
+	name, ok := codeNames[e.code]
+	if !ok {
+		name = fmt.Sprintf("CODE(%d)", e.code)
+	}
+	if e.message == "" {
+		return name
+	}
+	return name + ": " + e.message
+}
+
+// httpStatus - HTTP status code reported back to Webhook Relay
+func (e *statusError) httpStatus() int {
+	if code, ok := httpStatuses[e.code]; ok {
+		return code
+	}
+	return http.StatusInternalServerError
+}
+
+// parseStatus - returns error for non-OK grpc-status, grpc-message
+// is percent-encoded
+func parseStatus(status, message string) error {
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return fmt.Errorf("invalid grpc-status '%s'", status)
+	}
+	if code == codeOK {
+		return nil
+	}
+	if decoded, err := url.PathUnescape(message); err == nil {
+		message = decoded
+	}
+	return &statusError{code: code, message: message}
+}
+
+// codeFromHTTP - gRPC status for responses that are not gRPC,
+// e.g. from a proxy in front of the receiver
+func codeFromHTTP(status int) int {
+	switch status {
+	case http.StatusBadRequest:
+		return codeInternal
+	case http.StatusUnauthorized:
+		return codeUnauthenticated
+	case http.StatusForbidden:
+		return codePermissionDenied
+	case http.StatusNotFound:
+		return codeUnimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codeUnavailable
+	}
+	return codeUnknown
+}
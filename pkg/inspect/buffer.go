@@ -0,0 +1,91 @@
+// Package inspect keeps recently forwarded webhooks in memory and serves
+// them through a local web UI and JSON API, so they can be examined and
+// sent again without opening the hosted dashboard.
+package inspect
+
+import (
+	"sync"
+	"time"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// DefaultSize - default number of kept events
+var DefaultSize = 100
+
+// Entry - forwarded webhook and its delivery result
+type Entry struct {
+	// Seq - local sequence number, unique within the process
+	Seq        int64
+	ReceivedAt time.Time
+	// Event - webhook as it was received, before redaction
+	Event types.Event
+	// Result - delivery result, nil if forwarder returned an error
+	Result *types.LogUpdateRequest
+	// Error - forwarder error
+	Error    string
+	Duration time.Duration
+	// ResendOf - sequence number of the resent entry
+	ResendOf int64
+
+	// forwarder - forwarder the event came through, used to resend it
+	forwarder *Forwarder
+}
+
+// Buffer - ring buffer of the most recent entries
+type Buffer struct {
+	mu      sync.Mutex
+	entries []*Entry
+	next    int
+	seq     int64
+}
+
+// NewBuffer - creates buffer that keeps up to size entries
+func NewBuffer(size int) *Buffer {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Buffer{entries: make([]*Entry, size)}
+}
+
+// Add - stores entry, overwriting the oldest one when buffer is full,
+// and assigns its sequence number
+func (b *Buffer) Add(entry *Entry) *Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	entry.Seq = b.seq
+	b.entries[b.next] = entry
+	b.next = (b.next + 1) % len(b.entries)
+	return entry
+}
+
+// List - returns entries, newest first
+func (b *Buffer) List() []*Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries := make([]*Entry, 0, len(b.entries))
+	for i := 1; i <= len(b.entries); i++ {
+		entry := b.entries[(b.next-i+len(b.entries))%len(b.entries)]
+		if entry == nil {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// Get - returns entry by sequence number, nil if it was overwritten
+func (b *Buffer) Get(seq int64) *Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, entry := range b.entries {
+		if entry != nil && entry.Seq == seq {
+			return entry
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package inspect
+
+import (
+	"time"
+
+	"github.com/webhookrelay/relay-go/pkg/forward"
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+var _ forward.Forwarder = &Forwarder{}
+
+// Forwarder - forwarder that keeps forwarded events in a buffer
+type Forwarder struct {
+	next   forward.Forwarder
+	buffer *Buffer
+}
+
+// NewForwarder - wraps forwarder, every forwarded event is added to buffer
+func NewForwarder(next forward.Forwarder, buffer *Buffer) *Forwarder {
+	return &Forwarder{next: next, buffer: buffer}
+}
+
+// Forward - forwards webhook and stores it with its result
+func (f *Forwarder) Forward(wh types.Event) (*types.LogUpdateRequest, error) {
+	_, resp, err := f.forward(wh, 0)
+	return resp, err
+}
+
+// Resend - forwards buffered event again through the forwarder it came
+// from, returns the new entry or nil if the event is no longer buffered
+func (b *Buffer) Resend(seq int64) *Entry {
+	entry := b.Get(seq)
+	if entry == nil || entry.forwarder == nil {
+		return nil
+	}
+	resent, _, _ := entry.forwarder.forward(entry.Event, seq)
+	return resent
+}
+
+func (f *Forwarder) forward(wh types.Event, resendOf int64) (*Entry, *types.LogUpdateRequest, error) {
+	entry := &Entry{
+		ReceivedAt: wh.Meta.ReceivedAt,
+		Event:      wh,
+		ResendOf:   resendOf,
+		forwarder:  f,
+	}
+	if resendOf != 0 || entry.ReceivedAt.IsZero() {
+		entry.ReceivedAt = time.Now().UTC()
+	}
+
+	start := time.Now()
+	resp, err := f.next.Forward(wh)
+	entry.Duration = time.Since(start)
+
+	entry.Result = resp
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return f.buffer.Add(entry), resp, err
+}
@@ -0,0 +1,254 @@
+package inspect
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+
+	"github.com/webhookrelay/relay-go/pkg/logger"
+	"github.com/webhookrelay/relay-go/pkg/redact"
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// ServerOpts - inspection server configuration
+type ServerOpts struct {
+	Buffer *Buffer
+	// Addr - address the server listens on, requests with a Host header
+	// other than Addr or a loopback name are rejected
+	Addr string
+	// Redaction - optional policy applied to displayed events
+	Redaction *redact.Policy
+	Logger    *zap.SugaredLogger
+}
+
+// Server - inspection UI and JSON API:
+//
+//	GET  /                           - HTML page with recent events
+//	GET  /api/events                 - recent events, newest first
+//	GET  /api/events/{seq}           - single event
+//	POST /api/events/{seq}/resend    - forwards event again, returns new event
+type Server struct {
+	opts   *ServerOpts
+	logger *zap.SugaredLogger
+	mux    *http.ServeMux
+}
+
+var _ http.Handler = &Server{}
+
+// NewServer - creates inspection server
+func NewServer(opts *ServerOpts) *Server {
+	if opts.Logger == nil {
+		opts.Logger = logger.GetLoggerInstance(logger.DefaultLogLevel).Sugar()
+	}
+	s := &Server{
+		opts:   opts,
+		logger: opts.Logger,
+		mux:    http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/", s.index)
+	s.mux.HandleFunc("/api/events", s.list)
+	s.mux.HandleFunc("/api/events/", s.event)
+	return s
+}
+
+// ServeHTTP - implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// pages from other sites resolving their names to this address
+	// (DNS rebinding) must not be able to read events
+	if !s.allowedHost(r.Host) {
+		http.Error(w, "host not allowed", http.StatusForbidden)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// allowedHost - whether Host header is the listen address or a
+// loopback name
+func (s *Server) allowedHost(host string) bool {
+	if s.opts.Addr != "" && strings.EqualFold(host, s.opts.Addr) {
+		return true
+	}
+	name := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		name = h
+	}
+	name = strings.Trim(name, "[]")
+	if strings.EqualFold(name, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(name)
+	return ip != nil && ip.IsLoopback()
+}
+
+// eventView - entry as displayed, with redaction applied
+type eventView struct {
+	Seq         int64         `json:"seq"`
+	ResendOf    int64         `json:"resend_of,omitempty"`
+	ReceivedAt  time.Time     `json:"received_at"`
+	DurationMs  float64       `json:"duration_ms"`
+	Event       types.Event   `json:"event"`
+	Destination string        `json:"destination"`
+	Response    *responseView `json:"response,omitempty"`
+	Error       string        `json:"error,omitempty"`
+}
+
+type responseView struct {
+	StatusCode int         `json:"status_code"`
+	Status     string      `json:"status"`
+	Headers    http.Header `json:"headers"`
+	Body       string      `json:"body"`
+	Retries    int         `json:"retries"`
+}
+
+func (s *Server) view(entry *Entry) *eventView {
+	event := s.opts.Redaction.Event(entry.Event)
+
+	destination := event.Meta.OutputDestination
+	if event.RawQuery != "" {
+		destination += "?" + event.RawQuery
+	}
+
+	v := &eventView{
+		Seq:         entry.Seq,
+		ResendOf:    entry.ResendOf,
+		ReceivedAt:  entry.ReceivedAt,
+		DurationMs:  float64(entry.Duration) / float64(time.Millisecond),
+		Event:       event,
+		Destination: destination,
+		Error:       entry.Error,
+	}
+	if entry.Result != nil {
+		v.Response = &responseView{
+			StatusCode: entry.Result.StatusCode,
+			Status:     entry.Result.Status.String(),
+			Headers:    s.opts.Redaction.For(&entry.Event.Meta).Header(entry.Result.ResponseHeaders),
+			Body:       string(entry.Result.ResponseBody),
+			Retries:    entry.Result.Retries,
+		}
+	}
+	return v
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	entries := s.opts.Buffer.List()
+	views := make([]*eventView, 0, len(entries))
+	for _, entry := range entries {
+		views = append(views, s.view(entry))
+	}
+	s.writeJSON(w, http.StatusOK, views)
+}
+
+func (s *Server) event(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/events/")
+	parts := strings.Split(path, "/")
+	seq, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || len(parts) > 2 || (len(parts) == 2 && parts[1] != "resend") {
+		http.NotFound(w, r)
+		return
+	}
+
+	if len(parts) == 1 {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		entry := s.opts.Buffer.Get(seq)
+		if entry == nil {
+			http.NotFound(w, r)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, s.view(entry))
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// pages from other sites must not be able to resend webhooks
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+		return
+	}
+
+	entry := s.opts.Buffer.Resend(seq)
+	if entry == nil {
+		http.NotFound(w, r)
+		return
+	}
+	s.logger.Infow("event resent",
+		"id", entry.Event.Meta.ID,
+		"seq", seq,
+	)
+
+	// HTML form submissions go back to the event list
+	if r.FormValue("redirect") != "" {
+		http.Redirect(w, r, "/#event-"+strconv.FormatInt(entry.Seq, 10), http.StatusSeeOther)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, s.view(entry))
+}
+
+func (s *Server) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	entries := s.opts.Buffer.List()
+	views := make([]*eventView, 0, len(entries))
+	for _, entry := range entries {
+		views = append(views, s.view(entry))
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := indexTemplate.Execute(w, views); err != nil {
+		s.logger.Errorw("failed to render inspection page",
+			"error", err,
+		)
+	}
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		s.logger.Errorw("failed to write response",
+			"error", err,
+		)
+	}
+}
+
+// sameOrigin - whether request comes from a page served by this server,
+// browsers send Origin with POST requests
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return u.Host == r.Host
+}
+
+// displayBody - body as text, binary bodies are summarised
+func displayBody(body string) string {
+	if !utf8.ValidString(body) {
+		return "[binary body, " + strconv.Itoa(len(body)) + " bytes]"
+	}
+	return body
+}
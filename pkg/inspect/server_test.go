@@ -0,0 +1,201 @@
+package inspect
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/webhookrelay/relay-go/pkg/redact"
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+type countingForwarder struct {
+	calls int32
+}
+
+func (f *countingForwarder) Forward(wh types.Event) (*types.LogUpdateRequest, error) {
+	atomic.AddInt32(&f.calls, 1)
+	return &types.LogUpdateRequest{
+		ID:              wh.Meta.ID,
+		StatusCode:      201,
+		Status:          types.RequestStatusSent,
+		ResponseBody:    []byte("created"),
+		ResponseHeaders: http.Header{"Content-Type": []string{"text/plain"}, "X-Session": []string{"secret"}},
+	}, nil
+}
+
+func TestBuffer(t *testing.T) {
+	b := NewBuffer(2)
+	for _, id := range []string{"1", "2", "3"} {
+		b.Add(&Entry{Event: types.Event{Meta: types.EventMeta{ID: id}}})
+	}
+
+	entries := b.List()
+	if len(entries) != 2 || entries[0].Event.Meta.ID != "3" || entries[1].Event.Meta.ID != "2" {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+	if b.Get(1) != nil {
+		t.Errorf("oldest entry should have been overwritten")
+	}
+	if entry := b.Get(3); entry == nil || entry.Event.Meta.ID != "3" {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, *Forwarder, *countingForwarder) {
+	t.Helper()
+	policy, err := redact.NewPolicy(&redact.Config{Rules: redact.Rules{Headers: []string{"Authorization", "X-Session"}}})
+	if err != nil {
+		t.Fatalf("failed to create policy: %s", err)
+	}
+
+	next := &countingForwarder{}
+	buffer := NewBuffer(10)
+	f := NewForwarder(next, buffer)
+	srv := httptest.NewServer(NewServer(&ServerOpts{
+		Buffer:    buffer,
+		Redaction: policy,
+	}))
+	return srv, f, next
+}
+
+func TestServerAPI(t *testing.T) {
+	srv, f, next := newTestServer(t)
+	defer srv.Close()
+
+	f.Forward(types.Event{
+		Method:  "POST",
+		Body:    `{"hello":"world"}`,
+		Headers: map[string][]string{"Authorization": {"Bearer secret"}},
+		Meta:    types.EventMeta{ID: "ev-1", OutputDestination: "http://localhost:3000/hook"},
+	})
+
+	resp, err := http.Get(srv.URL + "/api/events")
+	if err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	defer resp.Body.Close()
+
+	var events []*eventView
+	if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
+		t.Fatalf("failed to decode events: %s", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	ev := events[0]
+	if ev.Event.Meta.ID != "ev-1" || ev.Destination != "http://localhost:3000/hook" || ev.Response.StatusCode != 201 || ev.Response.Body != "created" {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+	if ev.Event.Headers["Authorization"][0] != redact.Mask {
+		t.Errorf("expected Authorization header to be redacted, got %v", ev.Event.Headers)
+	}
+	if ev.Response.Headers.Get("X-Session") != redact.Mask || ev.Response.Headers.Get("Content-Type") != "text/plain" {
+		t.Errorf("expected X-Session response header to be redacted, got %v", ev.Response.Headers)
+	}
+
+	// resend forwards original, unredacted event
+	resp, err = resend(srv, "/api/events/1/resend", srv.URL)
+	if err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	defer resp.Body.Close()
+	var resent eventView
+	if err := json.NewDecoder(resp.Body).Decode(&resent); err != nil {
+		t.Fatalf("failed to decode event: %s", err)
+	}
+	if resent.Seq != 2 || resent.ResendOf != 1 || atomic.LoadInt32(&next.calls) != 2 {
+		t.Errorf("unexpected resent event: %+v", resent)
+	}
+	if entry := f.buffer.Get(2); entry.Event.Headers["Authorization"][0] != "Bearer secret" {
+		t.Errorf("expected original event to be resent, got %v", entry.Event.Headers)
+	}
+
+	resp, err = resend(srv, "/api/events/42/resend", srv.URL)
+	if err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown event, got %d", resp.StatusCode)
+	}
+}
+
+func resend(srv *httptest.Server, path, origin string) (*http.Response, error) {
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+path, nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	return http.DefaultClient.Do(req)
+}
+
+func TestServerRejectsCrossOriginResend(t *testing.T) {
+	srv, f, next := newTestServer(t)
+	defer srv.Close()
+
+	f.Forward(types.Event{Meta: types.EventMeta{ID: "ev-1"}})
+
+	for _, origin := range []string{"https://evil.example.com", ""} {
+		resp, err := resend(srv, "/api/events/1/resend", origin)
+		if err != nil {
+			t.Fatalf("request failed: %s", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden || atomic.LoadInt32(&next.calls) != 1 {
+			t.Errorf("expected resend with origin %q to be rejected, got %d", origin, resp.StatusCode)
+		}
+	}
+}
+
+func TestServerRejectsUnknownHost(t *testing.T) {
+	s := NewServer(&ServerOpts{Buffer: NewBuffer(10), Addr: "0.0.0.0:7070"})
+
+	for host, allowed := range map[string]bool{
+		"0.0.0.0:7070":        true,
+		"localhost:7070":      true,
+		"127.0.0.1:7070":      true,
+		"[::1]:7070":          true,
+		"evil.example.com":    false,
+		"evil.example.com:80": false,
+		"192.168.1.10:7070":   false,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/events", nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if allowed && rec.Code != http.StatusOK {
+			t.Errorf("expected host %s to be allowed, got %d", host, rec.Code)
+		}
+		if !allowed && rec.Code != http.StatusForbidden {
+			t.Errorf("expected host %s to be rejected, got %d", host, rec.Code)
+		}
+	}
+}
+
+func TestServerIndex(t *testing.T) {
+	srv, f, _ := newTestServer(t)
+	defer srv.Close()
+
+	f.Forward(types.Event{
+		Method: "POST",
+		Body:   `<script>alert(1)</script>`,
+		Meta:   types.EventMeta{ID: "ev-1", OutputDestination: "http://localhost:3000/hook"},
+	})
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	defer resp.Body.Close()
+	page, _ := ioutil.ReadAll(resp.Body)
+
+	for _, expected := range []string{"http://localhost:3000/hook", "201", "created", "&lt;script&gt;", "/api/events/1/resend"} {
+		if !strings.Contains(string(page), expected) {
+			t.Errorf("expected %q in page:\n%s", expected, page)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package inspect
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+var indexTemplate = template.Must(template.New("index").Funcs(template.FuncMap{
+	"body": func(ev types.Event) string {
+		raw, err := ev.RawBody()
+		if err != nil {
+			return ev.Body
+		}
+		return displayBody(string(raw))
+	},
+	"text":    displayBody,
+	"headers": formatHeaders,
+}).Parse(indexHTML))
+
+func formatHeaders(h http.Header) string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		for _, v := range h[name] {
+			b.WriteString(name + ": " + v + "\n")
+		}
+	}
+	return b.String()
+}
+
+const indexHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>relayd - recent webhooks</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+details { border: 1px solid #ddd; border-radius: 4px; margin-bottom: .5em; padding: .5em 1em; }
+summary { cursor: pointer; }
+pre { background: #f6f6f6; padding: .5em; overflow-x: auto; white-space: pre-wrap; word-break: break-all; }
+.ok { color: #2a7d2a; } .failed { color: #b22; }
+.muted { color: #777; }
+form { display: inline; }
+</style>
+</head>
+<body>
+<h1>Recent webhooks</h1>
+<p class="muted">Newest first, also available as <a href="/api/events">JSON</a>.</p>
+{{range .}}
+<details id="event-{{.Seq}}">
+<summary>
+#{{.Seq}} {{.ReceivedAt.Format "15:04:05.000"}} {{.Event.Method}} {{.Destination}}
+{{if .Response}}{{if and (ge .Response.StatusCode 200) (lt .Response.StatusCode 400)}}<span class="ok">{{.Response.StatusCode}}</span>{{else}}<span class="failed">{{if .Response.StatusCode}}{{.Response.StatusCode}}{{else}}{{.Response.Status}}{{end}}</span>{{end}}{{else}}<span class="failed">error</span>{{end}}
+<span class="muted">{{printf "%.1f" .DurationMs}}ms{{if .ResendOf}}, resend of #{{.ResendOf}}{{end}}</span>
+</summary>
+<p class="muted">Event {{.Event.Meta.ID}}, bucket {{.Event.Meta.BucketName}}, input {{.Event.Meta.InputName}}, output {{.Event.Meta.OutputName}}</p>
+<h3>Request</h3>
+<pre>{{headers .Event.Headers}}</pre>
+<pre>{{body .Event}}</pre>
+<h3>Response</h3>
+{{if .Error}}<pre class="failed">{{.Error}}</pre>{{end}}
+{{with .Response}}
+<p>Status {{.StatusCode}} ({{.Status}}), retries: {{.Retries}}</p>
+<pre>{{headers .Headers}}</pre>
+<pre>{{text .Body}}</pre>
+{{end}}
+<form method="post" action="/api/events/{{.Seq}}/resend?redirect=1"><button type="submit">Resend</button></form>
+</details>
+{{else}}
+<p>No webhooks received yet.</p>
+{{end}}
+</body>
+</html>
+`
@@ -0,0 +1,161 @@
+// Package jsonpath implements a small subset of JSONPath for selecting
+// values in decoded JSON documents: $.a.b, a.b, $.items[0].id,
+// $.items[*].id, $.a.*.b and $['odd key'].
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Path - parsed path expression
+type Path struct {
+	expr     string
+	segments []segment
+}
+
+type segment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// Parse - parses path expression, leading '$' is optional
+func Parse(expr string) (*Path, error) {
+	p := &Path{expr: expr}
+
+	s := strings.TrimSpace(expr)
+	s = strings.TrimPrefix(s, "$")
+	if s == "" {
+		return p, nil
+	}
+	if s[0] != '.' && s[0] != '[' {
+		s = "." + s
+	}
+
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			key := s[:end]
+			if key == "" {
+				return nil, fmt.Errorf("invalid path '%s': empty key", expr)
+			}
+			if key == "*" {
+				p.segments = append(p.segments, segment{wildcard: true})
+			} else {
+				p.segments = append(p.segments, segment{key: key})
+			}
+			s = s[end:]
+		case '[':
+			end := strings.Index(s, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path '%s': missing ']'", expr)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+			switch {
+			case inner == "*":
+				p.segments = append(p.segments, segment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				p.segments = append(p.segments, segment{key: inner[1 : len(inner)-1]})
+			default:
+				idx, err := strconv.Atoi(inner)
+				if err != nil || idx < 0 {
+					return nil, fmt.Errorf("invalid path '%s': bad index '%s'", expr, inner)
+				}
+				p.segments = append(p.segments, segment{index: idx, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("invalid path '%s': unexpected '%c'", expr, s[0])
+		}
+	}
+	return p, nil
+}
+
+// MustParse - parses path expression and panics on error
+func MustParse(expr string) *Path {
+	p, err := Parse(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String - returns original expression
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Get - returns all values matching the path
+func (p *Path) Get(doc interface{}) []interface{} {
+	var out []interface{}
+	walk(doc, p.segments, func(v interface{}, _ func(interface{})) {
+		out = append(out, v)
+	})
+	return out
+}
+
+// Replace - replaces all values matching the path with fn result and
+// returns number of replaced values. Root document cannot be replaced
+func (p *Path) Replace(doc interface{}, fn func(interface{}) interface{}) int {
+	if len(p.segments) == 0 {
+		return 0
+	}
+	n := 0
+	walk(doc, p.segments, func(v interface{}, set func(interface{})) {
+		set(fn(v))
+		n++
+	})
+	return n
+}
+
+func walk(v interface{}, segments []segment, visit func(v interface{}, set func(interface{}))) {
+	if len(segments) == 0 {
+		visit(v, func(interface{}) {})
+		return
+	}
+	seg, rest := segments[0], segments[1:]
+
+	step := func(child interface{}, set func(interface{})) {
+		if len(rest) == 0 {
+			visit(child, set)
+			return
+		}
+		walk(child, rest, visit)
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if seg.isIndex {
+			return
+		}
+		if seg.wildcard {
+			for k, child := range val {
+				k := k
+				step(child, func(n interface{}) { val[k] = n })
+			}
+			return
+		}
+		if child, ok := val[seg.key]; ok {
+			step(child, func(n interface{}) { val[seg.key] = n })
+		}
+	case []interface{}:
+		if seg.wildcard {
+			for i, child := range val {
+				i := i
+				step(child, func(n interface{}) { val[i] = n })
+			}
+			return
+		}
+		if seg.isIndex && seg.index < len(val) {
+			step(val[seg.index], func(n interface{}) { val[seg.index] = n })
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func decode(t *testing.T, s string) interface{} {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(s), &doc); err != nil {
+		t.Fatalf("failed to decode: %s", err)
+	}
+	return doc
+}
+
+func TestGet(t *testing.T) {
+	doc := decode(t, `{"a":{"b":1},"items":[{"id":"x"},{"id":"y"}],"odd key":true}`)
+
+	cases := []struct {
+		expr string
+		want []interface{}
+	}{
+		{expr: "$.a.b", want: []interface{}{1.0}},
+		{expr: "a.b", want: []interface{}{1.0}},
+		{expr: "$.items[1].id", want: []interface{}{"y"}},
+		{expr: "$.items[*].id", want: []interface{}{"x", "y"}},
+		{expr: "$['odd key']", want: []interface{}{true}},
+		{expr: "$.missing.b", want: nil},
+		{expr: "$.items[5]", want: nil},
+	}
+
+	for _, c := range cases {
+		got := MustParse(c.expr).Get(doc)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: expected %v, got %v", c.expr, c.want, got)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	doc := decode(t, `{"cards":[{"number":"4111"},{"number":"5555"}],"name":"x"}`)
+
+	n := MustParse("$.cards[*].number").Replace(doc, func(interface{}) interface{} { return "***" })
+	if n != 2 {
+		t.Errorf("expected 2 replacements, got %d", n)
+	}
+
+	bts, _ := json.Marshal(doc)
+	want := `{"cards":[{"number":"***"},{"number":"***"}],"name":"x"}`
+	if string(bts) != want {
+		t.Errorf("expected %s, got %s", want, string(bts))
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{"$.a..b", "$.a[1", "$.a[x]", "$.a[-1]"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("%s: expected an error", expr)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var _ Lock = &FileLock{}
+
+// FileLock - lease stored in a file, suitable for shared storage such as
+// NFS or a volume mounted into several pods. Updates to the lease are
+// serialised through an exclusively created <path>.lock file
+type FileLock struct {
+	path string
+
+	mu  sync.Mutex
+	ttl time.Duration
+}
+
+type fileLease struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// NewFileLock - creates file lock backend, lease is stored in path
+func NewFileLock(path string) *FileLock {
+	return &FileLock{path: path}
+}
+
+// TryAcquire - acquires or renews the lease
+func (l *FileLock) TryAcquire(ctx context.Context, holder string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	l.ttl = ttl
+	l.mu.Unlock()
+
+	acquired := false
+	err := l.withMutex(func() error {
+		lease, err := l.read()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if lease.Holder != "" && lease.Holder != holder && now.Before(lease.Expires) {
+			return nil
+		}
+		acquired = true
+		return l.write(&fileLease{Holder: holder, Expires: now.Add(ttl)})
+	})
+	return acquired, err
+}
+
+// Release - releases the lease
+func (l *FileLock) Release(ctx context.Context, holder string) error {
+	return l.withMutex(func() error {
+		lease, err := l.read()
+		if err != nil {
+			return err
+		}
+		if lease.Holder != holder {
+			return nil
+		}
+		return l.write(&fileLease{})
+	})
+}
+
+// staleMutexAge - age after which mutex file of a crashed process is taken
+// over. Mutex is held only while the lease file is updated, a fraction of
+// the lease leaves the leader enough time to renew before its deadline
+func (l *FileLock) staleMutexAge() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.ttl <= 0 {
+		return DefaultLeaseDuration / 4
+	}
+	return l.ttl / 4
+}
+
+func (l *FileLock) withMutex(fn func() error) error {
+	mutex := l.path + ".lock"
+	f, err := os.OpenFile(mutex, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil && os.IsExist(err) && l.takeOver(mutex) {
+		f, err = os.OpenFile(mutex, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	}
+	if err != nil {
+		if !os.IsExist(err) {
+			return fmt.Errorf("failed to create lock file: %s", err)
+		}
+		// somebody else is updating the lease
+		return ErrBusy
+	}
+	owned, err := f.Stat()
+	f.Close()
+	if err != nil {
+		os.Remove(mutex)
+		return fmt.Errorf("failed to create lock file: %s", err)
+	}
+	defer func() {
+		// mutex could have been taken over if this process stalled,
+		// only removing it while it's still ours
+		if info, err := os.Stat(mutex); err == nil && os.SameFile(owned, info) {
+			os.Remove(mutex)
+		}
+	}()
+
+	return fn()
+}
+
+// takeOver - removes mutex file left by a crashed process. Mutex is renamed
+// away first and put back if another replica replaced the stale mutex in
+// the meantime, so a fresh mutex is never removed
+func (l *FileLock) takeOver(mutex string) bool {
+	stale, err := os.Stat(mutex)
+	if err != nil || time.Since(stale.ModTime()) <= l.staleMutexAge() {
+		return false
+	}
+
+	moved := mutex + ".stale-" + strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.Itoa(os.Getpid())
+	if err := os.Rename(mutex, moved); err != nil {
+		return false
+	}
+	info, err := os.Stat(moved)
+	if err == nil && !os.SameFile(stale, info) {
+		// link fails if the path was taken again, rename would
+		// replace that mutex
+		os.Link(moved, mutex)
+	}
+	os.Remove(moved)
+	return err == nil && os.SameFile(stale, info)
+}
+
+func (l *FileLock) read() (*fileLease, error) {
+	var lease fileLease
+	bts, err := ioutil.ReadFile(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &lease, nil
+		}
+		return nil, fmt.Errorf("failed to read lease: %s", err)
+	}
+	if len(bts) == 0 {
+		return &lease, nil
+	}
+	err = json.Unmarshal(bts, &lease)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse lease: %s", err)
+	}
+	return &lease, nil
+}
+
+func (l *FileLock) write(lease *fileLease) error {
+	bts, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(l.path), filepath.Base(l.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write lease: %s", err)
+	}
+	_, err = tmp.Write(bts)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write lease: %s", err)
+	}
+	return os.Rename(tmp.Name(), l.path)
+}
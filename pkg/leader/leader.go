@@ -0,0 +1,151 @@
+// Package leader implements lease based leader election so only one of
+// several relayd replicas subscribes to buckets at a time.
+package leader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/webhookrelay/relay-go/pkg/logger"
+)
+
+// default election timings, replica takes over within
+// LeaseDuration + RetryPeriod after the leader is gone
+var (
+	DefaultLeaseDuration = 15 * time.Second
+	DefaultRetryPeriod   = 2 * time.Second
+)
+
+// Opts - elector configuration
+type Opts struct {
+	Lock Lock
+	// ID - unique replica identity, defaults to hostname and process ID
+	ID string
+	// LeaseDuration - how long the lease is valid without renewal
+	LeaseDuration time.Duration
+	// RetryPeriod - how often leader renews and followers try to
+	// acquire the lease
+	RetryPeriod time.Duration
+	Logger      *zap.SugaredLogger
+}
+
+// Elector - campaigns for leadership
+type Elector struct {
+	opts *Opts
+
+	mu     sync.Mutex
+	leader bool
+
+	logger *zap.SugaredLogger
+}
+
+// New - creates new elector
+func New(opts *Opts) *Elector {
+	if opts.Logger == nil {
+		opts.Logger = logger.GetLoggerInstance(logger.DefaultLogLevel).Sugar()
+	}
+	if opts.ID == "" {
+		hostname, _ := os.Hostname()
+		opts.ID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	if opts.LeaseDuration == 0 {
+		opts.LeaseDuration = DefaultLeaseDuration
+	}
+	if opts.RetryPeriod == 0 {
+		opts.RetryPeriod = DefaultRetryPeriod
+	}
+
+	return &Elector{
+		opts:   opts,
+		logger: opts.Logger,
+	}
+}
+
+// IsLeader - returns true while this replica holds the lease
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader
+}
+
+// Run - campaigns for leadership until ctx is cancelled. While elected, fn
+// is running with a context that is cancelled when leadership is lost. Leader
+// steps down if it cannot renew the lease within 2/3 of its duration, before
+// other replicas can acquire it.
+func (e *Elector) Run(ctx context.Context, fn func(ctx context.Context)) error {
+	ticker := time.NewTicker(e.opts.RetryPeriod)
+	defer ticker.Stop()
+
+	renewDeadline := e.opts.LeaseDuration * 2 / 3
+
+	var (
+		lastRenew time.Time
+		cancel    context.CancelFunc
+		done      chan struct{}
+	)
+
+	stepDown := func() {
+		if cancel == nil {
+			return
+		}
+		cancel()
+		<-done
+		cancel = nil
+		e.setLeader(false)
+	}
+
+	defer func() {
+		stepDown()
+		// letting other replicas take over without waiting for expiry
+		err := e.opts.Lock.Release(context.Background(), e.opts.ID)
+		if err != nil {
+			e.logger.Warnw("failed to release leader lease", "error", err)
+		}
+	}()
+
+	for {
+		acquired, err := e.opts.Lock.TryAcquire(ctx, e.opts.ID, e.opts.LeaseDuration)
+		switch {
+		case err == ErrBusy:
+			e.logger.Debugw("leader lease is busy", "id", e.opts.ID)
+		case err != nil:
+			e.logger.Warnw("leader election failed", "error", err, "id", e.opts.ID)
+		case acquired:
+			lastRenew = time.Now()
+			if cancel == nil {
+				e.logger.Infow("became leader", "id", e.opts.ID)
+				e.setLeader(true)
+
+				var leaderCtx context.Context
+				leaderCtx, cancel = context.WithCancel(ctx)
+				done = make(chan struct{})
+				go func() {
+					defer close(done)
+					fn(leaderCtx)
+				}()
+			}
+		}
+
+		if cancel != nil && (!acquired && err == nil || time.Since(lastRenew) > renewDeadline) {
+			e.logger.Warnw("lost leadership", "id", e.opts.ID)
+			stepDown()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Elector) setLeader(leader bool) {
+	e.mu.Lock()
+	e.leader = leader
+	e.mu.Unlock()
+}
@@ -0,0 +1,153 @@
+package leader
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("condition not met within %s", timeout)
+}
+
+func testFailover(t *testing.T, lock Lock) {
+	leaseDuration := 300 * time.Millisecond
+	retryPeriod := 50 * time.Millisecond
+
+	a := New(&Opts{Lock: lock, ID: "a", LeaseDuration: leaseDuration, RetryPeriod: retryPeriod})
+	b := New(&Opts{Lock: lock, ID: "b", LeaseDuration: leaseDuration, RetryPeriod: retryPeriod})
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	defer cancelA()
+	ctxB, cancelB := context.WithCancel(context.Background())
+	defer cancelB()
+
+	running := make(chan string, 10)
+	run := func(id string) func(ctx context.Context) {
+		return func(ctx context.Context) {
+			running <- id
+			<-ctx.Done()
+		}
+	}
+
+	doneA := make(chan struct{})
+	go func() {
+		a.Run(ctxA, run("a"))
+		close(doneA)
+	}()
+	waitFor(t, time.Second, a.IsLeader)
+
+	go b.Run(ctxB, run("b"))
+
+	// follower should not take over while leader renews
+	time.Sleep(2 * leaseDuration)
+	if b.IsLeader() {
+		t.Fatalf("expected only one leader")
+	}
+
+	// stopping the leader
+	cancelA()
+	<-doneA
+	if a.IsLeader() {
+		t.Errorf("stopped replica should not be a leader")
+	}
+
+	waitFor(t, leaseDuration+2*retryPeriod, b.IsLeader)
+
+	if id := <-running; id != "a" {
+		t.Errorf("expected 'a' to run first, got: %s", id)
+	}
+	if id := <-running; id != "b" {
+		t.Errorf("expected 'b' to run after failover, got: %s", id)
+	}
+}
+
+func TestMemoryLockFailover(t *testing.T) {
+	testFailover(t, NewMemoryLock())
+}
+
+func TestFileLockFailover(t *testing.T) {
+	dir, err := ioutil.TempDir("", "leader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testFailover(t, NewFileLock(filepath.Join(dir, "relayd.lease")))
+}
+
+func TestLeaderStepsDownWhenLeaseIsTaken(t *testing.T) {
+	lock := NewMemoryLock()
+	e := New(&Opts{Lock: lock, ID: "a", LeaseDuration: 200 * time.Millisecond, RetryPeriod: 20 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stopped := make(chan struct{})
+	go e.Run(ctx, func(ctx context.Context) {
+		<-ctx.Done()
+		close(stopped)
+	})
+	waitFor(t, time.Second, e.IsLeader)
+
+	// simulating another replica that took over an expired lease
+	lock.mu.Lock()
+	lock.holder = "b"
+	lock.expires = time.Now().Add(time.Minute)
+	lock.mu.Unlock()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatalf("leader should have stopped")
+	}
+	if e.IsLeader() {
+		t.Errorf("expected replica to step down")
+	}
+}
+
+func TestFileLockStaleMutex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "leader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "relayd.lease")
+	lock := NewFileLock(path)
+	ttl := 400 * time.Millisecond
+
+	// mutex of a replica that is updating the lease right now
+	if err := ioutil.WriteFile(path+".lock", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lock.TryAcquire(context.Background(), "a", ttl); err != ErrBusy {
+		t.Fatalf("expected fresh mutex to be respected, got: %v", err)
+	}
+
+	// mutex left by a crashed replica, older than a quarter of the lease
+	old := time.Now().Add(-ttl / 2)
+	if err := os.Chtimes(path+".lock", old, old); err != nil {
+		t.Fatal(err)
+	}
+	acquired, err := lock.TryAcquire(context.Background(), "a", ttl)
+	if err != nil || !acquired {
+		t.Fatalf("expected stale mutex to be taken over, acquired: %v, error: %v", acquired, err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 1 {
+		t.Errorf("expected only the lease file to be left, got: %v", files)
+	}
+}
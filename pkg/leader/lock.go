@@ -0,0 +1,63 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBusy - returned when lease is being updated by another replica,
+// the operation should be retried later
+var ErrBusy = errors.New("lock is busy")
+
+// Lock - lease based lock backend used for leader election. Implementations
+// must be safe to use from multiple processes sharing the backend
+type Lock interface {
+	// TryAcquire - acquires or renews the lease for holder. Returns true
+	// when holder owns the lease until ttl expires
+	TryAcquire(ctx context.Context, holder string, ttl time.Duration) (bool, error)
+	// Release - releases the lease if it is owned by holder
+	Release(ctx context.Context, holder string) error
+}
+
+var _ Lock = &MemoryLock{}
+
+// MemoryLock - in-memory lock, shared between electors of a single
+// process. Useful for tests and local development
+type MemoryLock struct {
+	mu      sync.Mutex
+	holder  string
+	expires time.Time
+}
+
+// NewMemoryLock - creates in-memory lock
+func NewMemoryLock() *MemoryLock {
+	return &MemoryLock{}
+}
+
+// TryAcquire - acquires or renews the lease
+func (l *MemoryLock) TryAcquire(ctx context.Context, holder string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.holder != "" && l.holder != holder && now.Before(l.expires) {
+		return false, nil
+	}
+	l.holder = holder
+	l.expires = now.Add(ttl)
+	return true, nil
+}
+
+// Release - releases the lease
+func (l *MemoryLock) Release(ctx context.Context, holder string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.holder == holder {
+		l.holder = ""
+		l.expires = time.Time{}
+	}
+	return nil
+}
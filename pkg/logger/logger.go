@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"io"
 	"os"
 	"time"
 
@@ -51,10 +52,16 @@ func timeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 }
 
 func GetLoggerInstance(level zapcore.Level) *zap.Logger {
+	return NewLogger(level, os.Stdout)
+}
+
+// NewLogger - creates logger writing to out, e.g. os.Stderr when
+// standard output is used for data
+func NewLogger(level zapcore.Level, out io.Writer) *zap.Logger {
 
 	core := zapcore.NewCore(
 		zapcore.NewConsoleEncoder(getBaseLogConfig(level)),
-		zapcore.NewMultiWriteSyncer(zapcore.AddSync(os.Stdout)),
+		zapcore.NewMultiWriteSyncer(zapcore.AddSync(out)),
 		level,
 	)
 	return zap.New(core, zap.AddCaller())
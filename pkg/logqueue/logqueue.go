@@ -0,0 +1,299 @@
+// Package logqueue delivers webhook delivery results (log updates) to
+// Webhook Relay asynchronously. Updates are queued, sent in batches and
+// retried until they are accepted or expire, optionally surviving restarts.
+package logqueue
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/webhookrelay/relay-go/pkg/logger"
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// defaults
+var (
+	DefaultSize        = 1000
+	DefaultBatchSize   = 50
+	DefaultRetryPeriod = 10 * time.Second
+	DefaultMaxAge      = 24 * time.Hour
+)
+
+// SendFunc - sends log updates, returns updates that could not be sent
+// and should be retried, and updates that were rejected by the server
+// and should be dropped
+type SendFunc func(updates []*types.LogUpdateRequest) (failed, rejected []*types.LogUpdateRequest)
+
+// Opts - queue configuration
+type Opts struct {
+	// Size - maximum number of queued updates and of updates waiting to
+	// be retried, the oldest ones are dropped when full
+	Size int
+	// BatchSize - maximum number of updates passed to SendFunc at once
+	BatchSize int
+	// RetryPeriod - how often updates that failed are sent again
+	RetryPeriod time.Duration
+	// Path - optional spool file to persist unsent updates across restarts
+	Path string
+	// MaxAge - updates that could not be sent for this long are dropped
+	MaxAge time.Duration
+	Logger *zap.SugaredLogger
+}
+
+// Stats - queue metrics
+type Stats struct {
+	// Pending - updates that were not accepted by the server yet
+	Pending int
+	// Sent - updates accepted by the server
+	Sent uint64
+	// Failed - failed send attempts, failed updates are retried
+	Failed uint64
+	// Dropped - updates that were rejected by the server, expired or
+	// didn't fit into the retry queue
+	Dropped uint64
+	// Lag - age of the oldest pending update
+	Lag time.Duration
+}
+
+// Queue - asynchronous log update queue
+type Queue struct {
+	opts  *Opts
+	ch    chan *types.LogUpdateRequest
+	spool *spool
+
+	mu      sync.Mutex
+	pending map[string]time.Time
+	// failed - updates waiting to be retried, including restored ones
+	failed []*types.LogUpdateRequest
+
+	sent       uint64
+	failedSend uint64
+	dropped    uint64
+
+	logger *zap.SugaredLogger
+}
+
+// New - creates queue, unsent updates are restored from Path
+func New(opts *Opts) (*Queue, error) {
+	if opts.Size <= 0 {
+		opts.Size = DefaultSize
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.RetryPeriod <= 0 {
+		opts.RetryPeriod = DefaultRetryPeriod
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = DefaultMaxAge
+	}
+	if opts.Logger == nil {
+		opts.Logger = logger.GetLoggerInstance(logger.DefaultLogLevel).Sugar()
+	}
+
+	q := &Queue{
+		opts:    opts,
+		ch:      make(chan *types.LogUpdateRequest, opts.Size),
+		pending: make(map[string]time.Time),
+		logger:  opts.Logger,
+	}
+
+	if opts.Path != "" {
+		s, restored, err := openSpool(opts.Path)
+		if err != nil {
+			return nil, err
+		}
+		q.spool = s
+		now := time.Now()
+		for _, u := range restored {
+			q.pending[u.ID] = now
+		}
+		q.failed = restored
+		q.trim()
+	}
+
+	return q, nil
+}
+
+// Push - queues update without blocking. When sender is busy or stopped
+// the update waits with failed ones for the next retry, dropping the
+// oldest updates if there are too many
+func (q *Queue) Push(update *types.LogUpdateRequest) error {
+	if q.spool != nil {
+		err := q.spool.add(update)
+		if err != nil {
+			return err
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending[update.ID] = time.Now()
+
+	select {
+	case q.ch <- update:
+	default:
+		q.failed = append(q.failed, update)
+		q.trim()
+	}
+	return nil
+}
+
+// Run - sends queued updates until context is cancelled. Updates queued
+// at the same time are sent together
+func (q *Queue) Run(ctx context.Context, send SendFunc) {
+	retry := time.NewTicker(q.opts.RetryPeriod)
+	defer retry.Stop()
+
+	q.retry(send)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-retry.C:
+			q.retry(send)
+		case update := <-q.ch:
+			batch := []*types.LogUpdateRequest{update}
+		COLLECT:
+			for len(batch) < q.opts.BatchSize {
+				select {
+				case update := <-q.ch:
+					batch = append(batch, update)
+				default:
+					break COLLECT
+				}
+			}
+			q.send(batch, send)
+		}
+	}
+}
+
+// retry - sends updates that failed previously
+func (q *Queue) retry(send SendFunc) {
+	q.mu.Lock()
+	failed := q.failed
+	q.failed = nil
+	q.mu.Unlock()
+
+	for len(failed) > 0 {
+		n := len(failed)
+		if n > q.opts.BatchSize {
+			n = q.opts.BatchSize
+		}
+		q.send(failed[:n], send)
+		failed = failed[n:]
+	}
+}
+
+func (q *Queue) send(batch []*types.LogUpdateRequest, send SendFunc) {
+	failed, rejected := send(batch)
+
+	retrySet := make(map[*types.LogUpdateRequest]bool, len(failed))
+	for _, u := range failed {
+		retrySet[u] = true
+	}
+	for _, u := range rejected {
+		q.logger.Warnw("delivery result was rejected by Webhook Relay, dropping it",
+			"id", u.ID,
+		)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	var expired int
+	for _, u := range failed {
+		if now.Sub(q.pending[u.ID]) < q.opts.MaxAge {
+			continue
+		}
+		q.logger.Warnw("delivery result could not be sent to Webhook Relay in time, dropping it",
+			"id", u.ID,
+			"max_age", q.opts.MaxAge.String(),
+		)
+		delete(retrySet, u)
+		expired++
+	}
+
+	for _, u := range batch {
+		if retrySet[u] {
+			continue
+		}
+		q.done(u)
+	}
+	for _, u := range failed {
+		if retrySet[u] {
+			q.failed = append(q.failed, u)
+		}
+	}
+	q.trim()
+
+	atomic.AddUint64(&q.sent, uint64(len(batch)-len(failed)-len(rejected)))
+	atomic.AddUint64(&q.failedSend, uint64(len(failed)))
+	atomic.AddUint64(&q.dropped, uint64(len(rejected)+expired))
+}
+
+// trim - drops the oldest failed updates that don't fit into the queue,
+// must be called with mu held
+func (q *Queue) trim() {
+	n := len(q.failed) - q.opts.Size
+	if n <= 0 {
+		return
+	}
+	q.logger.Warnw("too many delivery results waiting to be sent to Webhook Relay, dropping the oldest ones",
+		"count", n,
+		"size", q.opts.Size,
+	)
+	// updates that didn't fit into the send queue can be older than
+	// failed ones appended after them
+	sort.SliceStable(q.failed, func(i, j int) bool {
+		return q.pending[q.failed[i].ID].Before(q.pending[q.failed[j].ID])
+	})
+	for _, u := range q.failed[:n] {
+		q.done(u)
+	}
+	q.failed = append([]*types.LogUpdateRequest{}, q.failed[n:]...)
+	atomic.AddUint64(&q.dropped, uint64(n))
+}
+
+// done - forgets update, must be called with mu held
+func (q *Queue) done(u *types.LogUpdateRequest) {
+	delete(q.pending, u.ID)
+	if q.spool != nil {
+		q.spool.done(u.ID)
+	}
+}
+
+// Stats - returns queue metrics
+func (q *Queue) Stats() Stats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	s := Stats{
+		Pending: len(q.pending),
+		Sent:    atomic.LoadUint64(&q.sent),
+		Failed:  atomic.LoadUint64(&q.failedSend),
+		Dropped: atomic.LoadUint64(&q.dropped),
+	}
+	now := time.Now()
+	for _, queuedAt := range q.pending {
+		if lag := now.Sub(queuedAt); lag > s.Lag {
+			s.Lag = lag
+		}
+	}
+	return s
+}
+
+// Close - closes spool file
+func (q *Queue) Close() error {
+	if q.spool == nil {
+		return nil
+	}
+	return q.spool.close()
+}
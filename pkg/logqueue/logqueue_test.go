@@ -0,0 +1,266 @@
+package logqueue
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// recorder - SendFunc that records sent updates, fails while failing is
+// set and rejects updates with reject ID
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]string
+	failing bool
+	reject  string
+}
+
+func (r *recorder) send(updates []*types.LogUpdateRequest) (failed, rejected []*types.LogUpdateRequest) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failing {
+		return updates, nil
+	}
+	var ids []string
+	for _, u := range updates {
+		if u.ID == r.reject {
+			rejected = append(rejected, u)
+			continue
+		}
+		ids = append(ids, u.ID)
+	}
+	r.batches = append(r.batches, ids)
+	return nil, rejected
+}
+
+func (r *recorder) sent() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ids []string
+	for _, b := range r.batches {
+		ids = append(ids, b...)
+	}
+	return ids
+}
+
+func (r *recorder) setFailing(failing bool) {
+	r.mu.Lock()
+	r.failing = failing
+	r.mu.Unlock()
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestQueueBatches(t *testing.T) {
+	q, err := New(&Opts{BatchSize: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// queuing before sender starts so updates are batched
+	for _, id := range []string{"1", "2", "3", "4"} {
+		if err := q.Push(&types.LogUpdateRequest{ID: id}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	r := &recorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx, r.send)
+
+	waitFor(t, func() bool { return q.Stats().Sent == 4 })
+
+	if len(r.batches) != 2 || len(r.batches[0]) != 3 || len(r.batches[1]) != 1 {
+		t.Errorf("unexpected batches: %v", r.batches)
+	}
+	if s := q.Stats(); s.Pending != 0 || s.Lag != 0 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
+
+func TestQueueRetriesFailed(t *testing.T) {
+	q, err := New(&Opts{RetryPeriod: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	r := &recorder{failing: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx, r.send)
+
+	q.Push(&types.LogUpdateRequest{ID: "1"})
+	waitFor(t, func() bool { return q.Stats().Failed > 0 })
+
+	s := q.Stats()
+	if s.Pending != 1 || s.Lag == 0 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+
+	r.setFailing(false)
+	waitFor(t, func() bool { return q.Stats().Sent == 1 })
+	if ids := r.sent(); len(ids) != 1 || ids[0] != "1" {
+		t.Errorf("unexpected sent updates: %v", ids)
+	}
+}
+
+func TestQueueDropsRejected(t *testing.T) {
+	q, err := New(&Opts{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	r := &recorder{reject: "bad"}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx, r.send)
+
+	q.Push(&types.LogUpdateRequest{ID: "bad"})
+	q.Push(&types.LogUpdateRequest{ID: "good"})
+	waitFor(t, func() bool { s := q.Stats(); return s.Sent == 1 && s.Dropped == 1 })
+
+	if s := q.Stats(); s.Pending != 0 || s.Failed != 0 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
+
+func TestQueueDropsExpired(t *testing.T) {
+	q, err := New(&Opts{RetryPeriod: 20 * time.Millisecond, MaxAge: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	r := &recorder{failing: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx, r.send)
+
+	q.Push(&types.LogUpdateRequest{ID: "1"})
+	waitFor(t, func() bool { return q.Stats().Dropped == 1 })
+
+	if s := q.Stats(); s.Pending != 0 || s.Failed < 2 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
+
+func TestQueueBoundsFailed(t *testing.T) {
+	q, err := New(&Opts{Size: 2, RetryPeriod: time.Hour})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	r := &recorder{failing: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx, r.send)
+
+	for _, id := range []string{"1", "2", "3"} {
+		q.Push(&types.LogUpdateRequest{ID: id})
+	}
+	waitFor(t, func() bool { return q.Stats().Failed == 3 })
+
+	if s := q.Stats(); s.Pending != 2 || s.Dropped != 1 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+
+	// the oldest update was dropped
+	r.setFailing(false)
+	q.retry(r.send)
+	if ids := r.sent(); len(ids) != 2 || ids[0] != "2" || ids[1] != "3" {
+		t.Errorf("unexpected sent updates: %v", ids)
+	}
+}
+
+func TestQueuePushWhenFull(t *testing.T) {
+	q, err := New(&Opts{Size: 2, RetryPeriod: time.Hour})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// nothing is sending, pushes must not block
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		if err := q.Push(&types.LogUpdateRequest{ID: id}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if s := q.Stats(); s.Pending != 4 || s.Dropped != 1 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+
+	r := &recorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx, r.send)
+
+	waitFor(t, func() bool { return q.Stats().Sent == 4 })
+	for _, id := range r.sent() {
+		if id == "3" {
+			t.Errorf("expected the oldest waiting update to be dropped, got %v", r.sent())
+		}
+	}
+}
+
+func TestQueueSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logqueue")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "spool.jsonl")
+
+	q, err := New(&Opts{Path: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	r := &recorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	go q.Run(ctx, r.send)
+
+	q.Push(&types.LogUpdateRequest{ID: "sent", StatusCode: 200})
+	waitFor(t, func() bool { return q.Stats().Sent == 1 })
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+
+	// queued while sender is stopped, lost without a spool
+	q.Push(&types.LogUpdateRequest{ID: "unsent", StatusCode: 502, ResponseBody: []byte("bad gateway")})
+	q.Close()
+
+	q, err = New(&Opts{Path: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer q.Close()
+	if s := q.Stats(); s.Pending != 1 {
+		t.Fatalf("expected 1 restored update, got: %+v", s)
+	}
+
+	var restored []*types.LogUpdateRequest
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx, func(updates []*types.LogUpdateRequest) ([]*types.LogUpdateRequest, []*types.LogUpdateRequest) {
+		restored = append(restored, updates...)
+		return nil, nil
+	})
+	waitFor(t, func() bool { return q.Stats().Sent == 1 })
+
+	if len(restored) != 1 || restored[0].ID != "unsent" || restored[0].StatusCode != 502 || string(restored[0].ResponseBody) != "bad gateway" {
+		t.Errorf("unexpected restored updates: %+v", restored)
+	}
+}
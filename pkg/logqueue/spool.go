@@ -0,0 +1,181 @@
+package logqueue
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// compactAfter - number of sent updates after which spool file is
+// rewritten with pending updates only
+const compactAfter = 1000
+
+// spool - append only file of queued and sent updates
+type spool struct {
+	path string
+
+	mu      sync.Mutex
+	file    *os.File
+	pending map[string]*types.LogUpdateRequest
+	// order - pending IDs in the order they were queued
+	order []string
+	sent  int
+}
+
+type record struct {
+	ID     string                  `json:"id"`
+	Done   bool                    `json:"done,omitempty"`
+	Update *types.LogUpdateRequest `json:"update,omitempty"`
+}
+
+// openSpool - reads spool file, returns updates that were not sent
+func openSpool(path string) (*spool, []*types.LogUpdateRequest, error) {
+	s := &spool{
+		path:    path,
+		pending: make(map[string]*types.LogUpdateRequest),
+	}
+
+	f, err := os.Open(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("failed to open log spool: %s", err)
+	}
+	if err == nil {
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var r record
+			if json.Unmarshal(scanner.Bytes(), &r) != nil {
+				// skipping partially written lines
+				continue
+			}
+			if r.Done {
+				delete(s.pending, r.ID)
+				continue
+			}
+			if r.Update == nil {
+				continue
+			}
+			r.Update.ID = r.ID
+			if _, ok := s.pending[r.ID]; !ok {
+				s.order = append(s.order, r.ID)
+			}
+			s.pending[r.ID] = r.Update
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, nil, fmt.Errorf("failed to read log spool: %s", err)
+		}
+	}
+
+	err = s.compact()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	restored := make([]*types.LogUpdateRequest, 0, len(s.pending))
+	for _, id := range s.order {
+		restored = append(restored, s.pending[id])
+	}
+	return s, restored, nil
+}
+
+func (s *spool) add(update *types.LogUpdateRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pending[update.ID]; !ok {
+		s.order = append(s.order, update.ID)
+	}
+	s.pending[update.ID] = update
+	return s.write(&record{ID: update.ID, Update: update})
+}
+
+func (s *spool) done(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pending[id]; !ok {
+		return
+	}
+	delete(s.pending, id)
+	s.write(&record{ID: id, Done: true})
+
+	s.sent++
+	if s.sent >= compactAfter {
+		s.compact()
+	}
+}
+
+func (s *spool) write(r *record) error {
+	if s.file == nil {
+		return fmt.Errorf("log spool is closed")
+	}
+	bts, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = s.file.Write(append(bts, '\n'))
+	return err
+}
+
+// compact - rewrites spool file with pending updates only
+func (s *spool) compact() error {
+	order := s.order[:0]
+	for _, id := range s.order {
+		if _, ok := s.pending[id]; ok {
+			order = append(order, id)
+		}
+	}
+	s.order = order
+	s.sent = 0
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to compact log spool: %s", err)
+	}
+	w := bufio.NewWriter(tmp)
+	for _, id := range s.order {
+		bts, err := json.Marshal(&record{ID: id, Update: s.pending[id]})
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return err
+		}
+		w.Write(append(bts, '\n'))
+	}
+	err = w.Flush()
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to compact log spool: %s", err)
+	}
+
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file, err = os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open log spool: %s", err)
+	}
+	return nil
+}
+
+func (s *spool) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,40 @@
+package recorder
+
+import (
+	"fmt"
+
+	"github.com/webhookrelay/relay-go/pkg/forward"
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+var _ forward.Forwarder = &Forwarder{}
+
+// Forwarder - forwarder that archives events and their delivery results
+type Forwarder struct {
+	next     forward.Forwarder
+	recorder *Recorder
+}
+
+// NewForwarder - wraps forwarder, every forwarded event is recorded.
+// Redeliveries skipped by client deduplication never reach forwarders,
+// so they are not recorded
+func NewForwarder(next forward.Forwarder, recorder *Recorder) *Forwarder {
+	return &Forwarder{next: next, recorder: recorder}
+}
+
+// Forward - forwards webhook and records it with its result
+func (f *Forwarder) Forward(wh types.Event) (*types.LogUpdateRequest, error) {
+	resp, err := f.next.Forward(wh)
+
+	result := resp
+	if err != nil {
+		result = &types.LogUpdateRequest{
+			ID:           wh.Meta.ID,
+			Status:       types.RequestStatusFailed,
+			ResponseBody: []byte(fmt.Sprintf("request failed, error: %s", err)),
+		}
+	}
+	f.recorder.Record(wh, result)
+
+	return resp, err
+}
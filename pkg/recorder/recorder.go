@@ -0,0 +1,382 @@
+// Package recorder archives received webhooks and their delivery results
+// to rotating, gzip compressed JSON lines files.
+//
+// Archive format (version 1)
+//
+// Archive files are named events-<UTC time>.jsonl.gz, where time has the
+// 20060102T150405.000Z layout, so sorting names orders them by creation
+// time. The file that is being written has an additional .part suffix
+// and is renamed once it is rotated or the recorder is closed. Files may
+// contain several concatenated gzip members and a file left behind by a
+// crash can end with an incomplete line, readers should ignore it.
+//
+// Each line is a JSON object:
+//
+//	{
+//	  "version": 1,
+//	  "recorded_at": "2020-01-02T15:04:05.000000001Z",
+//	  "event": {...},
+//	  "result": {...}
+//	}
+//
+// event is types.Event as streamed by Webhook Relay, with redaction rules
+// applied, and result is types.LogUpdateRequest reported back to Webhook
+// Relay. Fields are only ever added within a version, readers should
+// ignore unknown fields and records with a newer version.
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/webhookrelay/relay-go/pkg/logger"
+	"github.com/webhookrelay/relay-go/pkg/redact"
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// FormatVersion - archive record format version
+const FormatVersion = 1
+
+// archive file naming
+const (
+	filePrefix  = "events-"
+	fileSuffix  = ".jsonl.gz"
+	partSuffix  = ".part"
+	timeLayout  = "20060102T150405.000Z"
+	flushPeriod = 5 * time.Second
+)
+
+// defaults
+var (
+	DefaultMaxFileSize = int64(100 << 20)
+	DefaultRotateEvery = time.Hour
+	DefaultBufferSize  = 1000
+)
+
+// Record - archived event and its delivery result
+type Record struct {
+	Version    int                     `json:"version"`
+	RecordedAt time.Time               `json:"recorded_at"`
+	Event      *types.Event            `json:"event"`
+	Result     *types.LogUpdateRequest `json:"result,omitempty"`
+}
+
+// Opts - recorder configuration
+type Opts struct {
+	// Dir - archive directory, created if it doesn't exist
+	Dir string
+	// MaxFileSize - uncompressed bytes written to a file before it is
+	// rotated
+	MaxFileSize int64
+	// RotateEvery - maximum time a file is written to
+	RotateEvery time.Duration
+	// MaxAge - archive files older than this are removed, zero keeps
+	// them forever
+	MaxAge time.Duration
+	// MaxTotalSize - oldest archive files are removed when all of them
+	// take more disk space than this, zero means no limit
+	MaxTotalSize int64
+	// BufferSize - records waiting to be written, records are dropped
+	// when the buffer is full so recording never slows down forwarding
+	BufferSize int
+	// Redaction - optional policy applied to recorded events
+	Redaction *redact.Policy
+	Logger    *zap.SugaredLogger
+}
+
+// Recorder - asynchronous event archive writer
+type Recorder struct {
+	dropped uint64
+
+	opts    *Opts
+	logger  *zap.SugaredLogger
+	records chan *Record
+	done    chan struct{}
+	// closed - set by Close, records are no longer accepted. Guards
+	// records channel from sends after it is closed
+	closedMu sync.RWMutex
+	closed   bool
+
+	// current archive file, only used by the writer goroutine
+	file    *os.File
+	gz      *gzip.Writer
+	w       *bufio.Writer
+	path    string
+	written int64
+	opened  time.Time
+}
+
+// New - creates recorder and starts writing records to opts.Dir
+func New(opts *Opts) (*Recorder, error) {
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = DefaultMaxFileSize
+	}
+	if opts.RotateEvery <= 0 {
+		opts.RotateEvery = DefaultRotateEvery
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultBufferSize
+	}
+	if opts.Logger == nil {
+		opts.Logger = logger.GetLoggerInstance(logger.DefaultLogLevel).Sugar()
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %s", err)
+	}
+
+	r := &Recorder{
+		opts:    opts,
+		logger:  opts.Logger,
+		records: make(chan *Record, opts.BufferSize),
+		done:    make(chan struct{}),
+	}
+	if err := r.finishParts(); err != nil {
+		return nil, err
+	}
+	r.cleanup()
+
+	go r.run()
+	return r, nil
+}
+
+// Record - queues event and its delivery result for archiving, never blocks
+func (r *Recorder) Record(event types.Event, result *types.LogUpdateRequest) {
+	event = r.opts.Redaction.Event(event)
+	rec := &Record{
+		Version:    FormatVersion,
+		RecordedAt: time.Now().UTC(),
+		Event:      &event,
+		Result:     result,
+	}
+
+	r.closedMu.RLock()
+	defer r.closedMu.RUnlock()
+	if r.closed {
+		// events delivered while shutting down are not archived
+		return
+	}
+
+	select {
+	case r.records <- rec:
+	default:
+		if dropped := atomic.AddUint64(&r.dropped, 1); dropped%1000 == 1 {
+			r.logger.Warnw("recording buffer is full, events are not archived",
+				"dropped", dropped,
+			)
+		}
+	}
+}
+
+// Dropped - number of records dropped because the buffer was full
+func (r *Recorder) Dropped() uint64 {
+	return atomic.LoadUint64(&r.dropped)
+}
+
+// Close - writes buffered records and closes current archive file
+func (r *Recorder) Close() error {
+	r.closedMu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.records)
+	}
+	r.closedMu.Unlock()
+	<-r.done
+	return nil
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(flushPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case rec, ok := <-r.records:
+			if !ok {
+				r.closeFile()
+				return
+			}
+			if err := r.write(rec); err != nil {
+				r.logger.Errorw("failed to archive event",
+					"id", rec.Event.Meta.ID,
+					"error", err,
+				)
+			}
+		case <-ticker.C:
+			if r.file == nil {
+				continue
+			}
+			if time.Since(r.opened) >= r.opts.RotateEvery {
+				r.closeFile()
+				continue
+			}
+			if err := r.flush(); err != nil {
+				r.logger.Errorw("failed to flush archive",
+					"path", r.path,
+					"error", err,
+				)
+			}
+		}
+	}
+}
+
+func (r *Recorder) write(rec *Record) error {
+	bts, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %s", err)
+	}
+
+	if r.file == nil {
+		if err := r.openFile(); err != nil {
+			return err
+		}
+	}
+
+	n, err := r.w.Write(append(bts, '\n'))
+	r.written += int64(n)
+	if err != nil {
+		return err
+	}
+
+	if r.written >= r.opts.MaxFileSize {
+		r.closeFile()
+	}
+	return nil
+}
+
+func (r *Recorder) openFile() error {
+	now := time.Now().UTC()
+	// names must stay unique when files are rotated within a millisecond
+	var (
+		path string
+		f    *os.File
+	)
+	for name := now; ; name = name.Add(time.Millisecond) {
+		path = filepath.Join(r.opts.Dir, filePrefix+name.Format(timeLayout)+fileSuffix)
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		path += partSuffix
+		var err error
+		f, err = os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create archive file: %s", err)
+		}
+		break
+	}
+
+	r.file = f
+	r.gz = gzip.NewWriter(f)
+	r.w = bufio.NewWriter(r.gz)
+	r.path = path
+	r.written = 0
+	r.opened = now
+	return nil
+}
+
+func (r *Recorder) flush() error {
+	if err := r.w.Flush(); err != nil {
+		return err
+	}
+	return r.gz.Flush()
+}
+
+// closeFile - completes current archive file and applies retention
+func (r *Recorder) closeFile() {
+	if r.file == nil {
+		return
+	}
+
+	err := r.w.Flush()
+	if err == nil {
+		err = r.gz.Close()
+	}
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		r.logger.Errorw("failed to close archive file",
+			"path", r.path,
+			"error", err,
+		)
+	}
+	if err := os.Rename(r.path, strings.TrimSuffix(r.path, partSuffix)); err != nil {
+		r.logger.Errorw("failed to complete archive file",
+			"path", r.path,
+			"error", err,
+		)
+	}
+
+	r.file, r.gz, r.w = nil, nil, nil
+	r.cleanup()
+}
+
+// finishParts - completes files left behind by a previous process
+func (r *Recorder) finishParts() error {
+	paths, err := filepath.Glob(filepath.Join(r.opts.Dir, filePrefix+"*"+fileSuffix+partSuffix))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := os.Rename(path, strings.TrimSuffix(path, partSuffix)); err != nil {
+			return fmt.Errorf("failed to complete archive file: %s", err)
+		}
+	}
+	return nil
+}
+
+// cleanup - removes archive files that are too old or exceed total size
+func (r *Recorder) cleanup() {
+	infos, err := ioutil.ReadDir(r.opts.Dir)
+	if err != nil {
+		r.logger.Errorw("failed to list archive files",
+			"error", err,
+		)
+		return
+	}
+
+	var files []os.FileInfo
+	var total int64
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		files = append(files, info)
+		total += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+
+	for _, info := range files {
+		expired := r.opts.MaxAge > 0 && time.Since(info.ModTime()) > r.opts.MaxAge
+		oversized := r.opts.MaxTotalSize > 0 && total > r.opts.MaxTotalSize
+		if !expired && !oversized {
+			break
+		}
+		if err := os.Remove(filepath.Join(r.opts.Dir, info.Name())); err != nil {
+			r.logger.Errorw("failed to remove archive file",
+				"name", info.Name(),
+				"error", err,
+			)
+			continue
+		}
+		total -= info.Size()
+	}
+}
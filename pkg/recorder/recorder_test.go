@@ -0,0 +1,178 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/webhookrelay/relay-go/pkg/redact"
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+type staticForwarder struct{}
+
+func (f *staticForwarder) Forward(wh types.Event) (*types.LogUpdateRequest, error) {
+	return &types.LogUpdateRequest{ID: wh.Meta.ID, StatusCode: 202, Status: types.RequestStatusSent}, nil
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "recorder")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	return dir
+}
+
+func readArchive(t *testing.T, dir string) (files []string, records []*Record) {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "events-*"))
+	if err != nil {
+		t.Fatalf("failed to list archive: %s", err)
+	}
+	for _, path := range files {
+		if !strings.HasSuffix(path, fileSuffix) {
+			t.Errorf("unexpected archive file: %s", path)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("failed to open archive file: %s", err)
+		}
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("failed to read archive file: %s", err)
+		}
+		sc := bufio.NewScanner(gz)
+		for sc.Scan() {
+			var rec Record
+			if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+				t.Fatalf("failed to unmarshal record: %s", err)
+			}
+			records = append(records, &rec)
+		}
+		if err := sc.Err(); err != nil {
+			t.Fatalf("failed to read archive file: %s", err)
+		}
+		f.Close()
+	}
+	return files, records
+}
+
+func TestForwarderRecords(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	policy, err := redact.NewPolicy(&redact.Config{Rules: redact.Rules{Fields: []string{"password"}}})
+	if err != nil {
+		t.Fatalf("failed to create policy: %s", err)
+	}
+	r, err := New(&Opts{Dir: dir, Redaction: policy})
+	if err != nil {
+		t.Fatalf("failed to create recorder: %s", err)
+	}
+
+	f := NewForwarder(&staticForwarder{}, r)
+	resp, err := f.Forward(types.Event{
+		Type: "webhook",
+		Body: `{"password":"hunter2"}`,
+		Meta: types.EventMeta{ID: "1"},
+	})
+	if err != nil || resp.StatusCode != 202 {
+		t.Fatalf("unexpected forward result: %v, %v", resp, err)
+	}
+	r.Close()
+
+	files, records := readArchive(t, dir)
+	if len(files) != 1 || len(records) != 1 {
+		t.Fatalf("expected 1 file with 1 record, got %d and %d", len(files), len(records))
+	}
+	rec := records[0]
+	if rec.Version != FormatVersion || rec.Event.Meta.ID != "1" || rec.Result.StatusCode != 202 {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+	if strings.Contains(rec.Event.Body, "hunter2") {
+		t.Errorf("recorded event was not redacted: %s", rec.Event.Body)
+	}
+}
+
+func TestRotationAndRetention(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	r, err := New(&Opts{Dir: dir, MaxFileSize: 1})
+	if err != nil {
+		t.Fatalf("failed to create recorder: %s", err)
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		r.Record(types.Event{Meta: types.EventMeta{ID: id}}, nil)
+	}
+	r.Close()
+
+	files, records := readArchive(t, dir)
+	if len(files) != 3 || len(records) != 3 {
+		t.Fatalf("expected 3 files with a record each, got %d and %d", len(files), len(records))
+	}
+	var newest int64
+	for _, path := range files[1:] {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("failed to stat archive file: %s", err)
+		}
+		newest += info.Size()
+	}
+
+	// reopening applies retention, keeping the newest files that fit
+	r, err = New(&Opts{Dir: dir, MaxTotalSize: newest})
+	if err != nil {
+		t.Fatalf("failed to create recorder: %s", err)
+	}
+	r.Close()
+
+	files, records = readArchive(t, dir)
+	if len(files) != 2 || records[0].Event.Meta.ID != "2" {
+		t.Errorf("expected oldest file to be removed, got %d files", len(files))
+	}
+}
+
+func TestRecordDoesNotBlock(t *testing.T) {
+	// recorder without a writer goroutine
+	r := &Recorder{
+		opts:    &Opts{},
+		logger:  zap.NewNop().Sugar(),
+		records: make(chan *Record, 1),
+	}
+
+	for i := 0; i < 3; i++ {
+		r.Record(types.Event{}, nil)
+	}
+	if r.Dropped() != 2 {
+		t.Errorf("expected 2 dropped records, got %d", r.Dropped())
+	}
+}
+
+func TestRecordAfterClose(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	r, err := New(&Opts{Dir: dir, Logger: zap.NewNop().Sugar()})
+	if err != nil {
+		t.Fatalf("failed to create recorder: %s", err)
+	}
+	r.Record(types.Event{Meta: types.EventMeta{ID: "1"}}, nil)
+	r.Close()
+
+	// webhooks still being delivered during shutdown must not panic
+	r.Record(types.Event{Meta: types.EventMeta{ID: "2"}}, nil)
+	r.Close()
+
+	if _, records := readArchive(t, dir); len(records) != 1 || records[0].Event.Meta.ID != "1" {
+		t.Errorf("expected only the record before close, got %d records", len(records))
+	}
+}
@@ -0,0 +1,100 @@
+package redact
+
+import (
+	"fmt"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// Config - global and per bucket redaction rules for incoming webhooks,
+// bucket rules are added to global rules. Example:
+//
+//	headers: [Authorization, Cookie]
+//	card_numbers: true
+//	buckets:
+//	  stripe:
+//	    paths: [$.data.object.billing_details.email]
+type Config struct {
+	Rules `yaml:",inline"`
+	// Buckets - additional rules keyed by bucket name or ID
+	Buckets map[string]*Rules `yaml:"buckets"`
+}
+
+// Policy - redacts incoming webhook events before they are logged,
+// persisted or exported
+type Policy struct {
+	global  *Redactor
+	buckets map[string]*Redactor
+}
+
+// NewPolicy - creates redaction policy
+func NewPolicy(cfg *Config) (*Policy, error) {
+	global, err := New(&cfg.Rules)
+	if err != nil {
+		return nil, err
+	}
+	p := &Policy{
+		global:  global,
+		buckets: make(map[string]*Redactor, len(cfg.Buckets)),
+	}
+	for bucket, rules := range cfg.Buckets {
+		if rules == nil {
+			continue
+		}
+		r, err := New(merge(&cfg.Rules, rules))
+		if err != nil {
+			return nil, fmt.Errorf("bucket '%s': %s", bucket, err)
+		}
+		p.buckets[bucket] = r
+	}
+	return p, nil
+}
+
+func merge(global, bucket *Rules) *Rules {
+	return &Rules{
+		Headers:     append(append([]string{}, global.Headers...), bucket.Headers...),
+		Fields:      append(append([]string{}, global.Fields...), bucket.Fields...),
+		Paths:       append(append([]string{}, global.Paths...), bucket.Paths...),
+		Patterns:    append(append([]string{}, global.Patterns...), bucket.Patterns...),
+		CardNumbers: global.CardNumbers || bucket.CardNumbers,
+	}
+}
+
+// For - returns redactor for the event bucket
+func (p *Policy) For(meta *types.EventMeta) *Redactor {
+	if p == nil {
+		return nil
+	}
+	if r, ok := p.buckets[meta.BucketName]; ok {
+		return r
+	}
+	if r, ok := p.buckets[meta.BucketID]; ok {
+		return r
+	}
+	return p.global
+}
+
+// Event - returns a redacted copy of the event
+func (p *Policy) Event(ev types.Event) types.Event {
+	if p == nil {
+		return ev
+	}
+	r := p.For(&ev.Meta)
+
+	ev.Headers = r.Header(ev.Headers)
+	ev.RawQuery = r.String(ev.RawQuery)
+	ev.Meta.OutputDestination = r.String(ev.Meta.OutputDestination)
+
+	raw, err := ev.RawBody()
+	if err != nil {
+		ev.Body = Mask
+		ev.BodyEncoding = types.BodyEncodingText
+		return ev
+	}
+	body, ok := r.Body(raw)
+	if !ok {
+		body = []byte(Mask)
+	}
+	ev.SetRawBody(body)
+	return ev
+}
@@ -0,0 +1,223 @@
+// Package redact removes secrets and personal data from webhook headers
+// and bodies before they leave relayd host or end up in local logs.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/webhookrelay/relay-go/pkg/jsonpath"
+)
+
+// Mask - replacement for redacted values
+const Mask = "[REDACTED]"
+
+// Rules - redaction rules
+type Rules struct {
+	// Headers - names of headers which values are masked, case insensitive
+	Headers []string `yaml:"headers"`
+	// Fields - JSON object keys which values are masked at any depth,
+	// case insensitive
+	Fields []string `yaml:"fields"`
+	// Paths - JSONPath-like body paths which values are masked,
+	// e.g. $.card.number or $.items[*].email
+	Paths []string `yaml:"paths"`
+	// Patterns - regular expressions masked in bodies, header values and
+	// query strings
+	Patterns []string `yaml:"patterns"`
+	// CardNumbers - mask payment card numbers (13-19 digits passing
+	// the Luhn check, optionally separated by spaces or dashes)
+	CardNumbers bool `yaml:"card_numbers"`
+}
+
+// Redactor - applies redaction rules
+type Redactor struct {
+	headers     map[string]bool
+	fields      map[string]bool
+	paths       []*jsonpath.Path
+	patterns    []*regexp.Regexp
+	cardNumbers bool
+}
+
+// New - creates redactor from rules
+func New(rules *Rules) (*Redactor, error) {
+	r := &Redactor{
+		headers:     make(map[string]bool, len(rules.Headers)),
+		fields:      make(map[string]bool, len(rules.Fields)),
+		cardNumbers: rules.CardNumbers,
+	}
+	for _, h := range rules.Headers {
+		if h = strings.TrimSpace(h); h != "" {
+			r.headers[http.CanonicalHeaderKey(h)] = true
+		}
+	}
+	for _, f := range rules.Fields {
+		if f = strings.TrimSpace(f); f != "" {
+			r.fields[strings.ToLower(f)] = true
+		}
+	}
+	for _, expr := range rules.Paths {
+		p, err := jsonpath.Parse(expr)
+		if err != nil {
+			return nil, err
+		}
+		r.paths = append(r.paths, p)
+	}
+	for _, expr := range rules.Patterns {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s': %s", expr, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Header - returns a copy of headers with masked values
+func (r *Redactor) Header(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+	out := make(http.Header, len(h))
+	for k, v := range h {
+		masked := make([]string, len(v))
+		for i := range v {
+			if r != nil && r.headers[http.CanonicalHeaderKey(k)] {
+				masked[i] = Mask
+				continue
+			}
+			masked[i] = r.String(v[i])
+		}
+		out[k] = masked
+	}
+	return out
+}
+
+// String - masks patterns and card numbers in a free form text
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, Mask)
+	}
+	if r.cardNumbers {
+		s = maskCardNumbers(s)
+	}
+	return s
+}
+
+// Body - returns body with masked JSON fields, paths and patterns. False
+// is returned when JSON rules are configured and body looks like JSON but
+// cannot be parsed (for example it was truncated), in which case rules
+// could not be applied and body should not be exposed
+func (r *Redactor) Body(body []byte) ([]byte, bool) {
+	if r == nil {
+		return body, true
+	}
+	if (len(r.fields) > 0 || len(r.paths) > 0) && looksLikeJSON(body) {
+		redacted, ok := r.redactJSON(body)
+		if !ok {
+			return nil, false
+		}
+		body = redacted
+	}
+	if len(r.patterns) > 0 || r.cardNumbers {
+		body = []byte(r.String(string(body)))
+	}
+	return body, true
+}
+
+func (r *Redactor) redactJSON(body []byte) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, false
+	}
+
+	redacted := r.redactFields(doc)
+	for _, p := range r.paths {
+		if p.Replace(doc, func(interface{}) interface{} { return Mask }) > 0 {
+			redacted = true
+		}
+	}
+	if !redacted {
+		return body, true
+	}
+
+	bts, err := json.Marshal(doc)
+	if err != nil {
+		return nil, false
+	}
+	return bts, true
+}
+
+// redactFields - masks matching fields, returns true if anything was masked
+func (r *Redactor) redactFields(v interface{}) bool {
+	if len(r.fields) == 0 {
+		return false
+	}
+	redacted := false
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			if r.fields[strings.ToLower(k)] {
+				val[k] = Mask
+				redacted = true
+				continue
+			}
+			if r.redactFields(child) {
+				redacted = true
+			}
+		}
+	case []interface{}:
+		for _, child := range val {
+			if r.redactFields(child) {
+				redacted = true
+			}
+		}
+	}
+	return redacted
+}
+
+func looksLikeJSON(body []byte) bool {
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+}
+
+var cardNumberRe = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+
+func maskCardNumbers(s string) string {
+	return cardNumberRe.ReplaceAllStringFunc(s, func(match string) string {
+		if luhn(match) {
+			return Mask
+		}
+		return match
+	})
+}
+
+// luhn - validates digits in s with the Luhn checksum, separators are ignored
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
@@ -0,0 +1,169 @@
+package redact
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+func TestHeader(t *testing.T) {
+	r, err := New(&Rules{Headers: []string{"authorization", "Set-Cookie"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	h := http.Header{}
+	h.Set("Authorization", "Bearer secret")
+	h.Add("Set-Cookie", "a=1")
+	h.Add("Set-Cookie", "b=2")
+	h.Set("Content-Type", "text/plain")
+
+	out := r.Header(h)
+	if out.Get("Authorization") != Mask {
+		t.Errorf("expected authorization to be redacted, got: %s", out.Get("Authorization"))
+	}
+	if len(out["Set-Cookie"]) != 2 || out["Set-Cookie"][1] != Mask {
+		t.Errorf("expected all cookies to be redacted, got: %v", out["Set-Cookie"])
+	}
+	if out.Get("Content-Type") != "text/plain" {
+		t.Errorf("unexpected content type: %s", out.Get("Content-Type"))
+	}
+	if h.Get("Authorization") != "Bearer secret" {
+		t.Errorf("original headers should not be modified")
+	}
+}
+
+func TestBody(t *testing.T) {
+	r, err := New(&Rules{Fields: []string{"password", "Token"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	cases := []struct {
+		name string
+		body string
+		want string
+		ok   bool
+	}{
+		{name: "nested", body: `{"user":{"password":"x","name":"a"},"items":[{"token":1}]}`, want: `{"items":[{"token":"[REDACTED]"}],"user":{"name":"a","password":"[REDACTED]"}}`, ok: true},
+		{name: "nothing to redact", body: `{"b": 1.50, "a": 2}`, want: `{"b": 1.50, "a": 2}`, ok: true},
+		{name: "not json", body: `password=x`, want: `password=x`, ok: true},
+		{name: "truncated json", body: `{"password":"x","na`, ok: false},
+	}
+
+	for _, c := range cases {
+		got, ok := r.Body([]byte(c.body))
+		if ok != c.ok {
+			t.Errorf("%s: expected ok %t, got %t", c.name, c.ok, ok)
+			continue
+		}
+		if ok && string(got) != c.want {
+			t.Errorf("%s: expected %s, got %s", c.name, c.want, string(got))
+		}
+	}
+}
+
+func TestNilRedactor(t *testing.T) {
+	var r *Redactor
+	body, ok := r.Body([]byte(`{"password":"x"}`))
+	if !ok || string(body) != `{"password":"x"}` {
+		t.Errorf("nil redactor should not modify body")
+	}
+}
+
+func TestBodyPathsAndPatterns(t *testing.T) {
+	r, err := New(&Rules{
+		Paths:       []string{"$.customer.email", "$.items[*].sku"},
+		Patterns:    []string{`sk_live_[a-zA-Z0-9]+`},
+		CardNumbers: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	body := `{"customer":{"email":"a@b.c","name":"x"},"items":[{"sku":"1"}],"key":"sk_live_abc","card":"4111 1111 1111 1111","order":1234567890123}`
+	want := `{"card":"[REDACTED]","customer":{"email":"[REDACTED]","name":"x"},"items":[{"sku":"[REDACTED]"}],"key":"[REDACTED]","order":1234567890123}`
+
+	got, ok := r.Body([]byte(body))
+	if !ok {
+		t.Fatalf("expected body to be redacted")
+	}
+	if string(got) != want {
+		t.Errorf("expected %s, got %s", want, string(got))
+	}
+
+	// patterns still apply to bodies that cannot be parsed
+	got, ok = r.Body([]byte(`card=5555-5555-5555-4444&x=1`))
+	if !ok || string(got) != `card=[REDACTED]&x=1` {
+		t.Errorf("unexpected body: %s", string(got))
+	}
+}
+
+func TestNewInvalidRules(t *testing.T) {
+	if _, err := New(&Rules{Patterns: []string{"("}}); err == nil {
+		t.Errorf("expected an error for invalid pattern")
+	}
+	if _, err := New(&Rules{Paths: []string{"$.a["}}); err == nil {
+		t.Errorf("expected an error for invalid path")
+	}
+}
+
+func TestPolicyEvent(t *testing.T) {
+	p, err := NewPolicy(&Config{
+		Rules: Rules{Headers: []string{"Authorization"}, CardNumbers: true},
+		Buckets: map[string]*Rules{
+			"stripe": {Paths: []string{"$.email"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ev := types.Event{
+		Meta:     types.EventMeta{BucketName: "stripe"},
+		Headers:  map[string][]string{"Authorization": {"Bearer x"}},
+		RawQuery: "card=4111111111111111",
+		Body:     `{"email":"a@b.c"}`,
+	}
+
+	redacted := p.Event(ev)
+	if redacted.Headers["Authorization"][0] != Mask {
+		t.Errorf("expected authorization header to be redacted")
+	}
+	if redacted.RawQuery != "card="+Mask {
+		t.Errorf("unexpected query: %s", redacted.RawQuery)
+	}
+	if redacted.Body != `{"email":"[REDACTED]"}` {
+		t.Errorf("unexpected body: %s", redacted.Body)
+	}
+	if ev.Headers["Authorization"][0] != "Bearer x" {
+		t.Errorf("original event should not be modified")
+	}
+
+	// bucket rules don't apply to other buckets
+	ev.Meta.BucketName = "github"
+	if p.Event(ev).Body != ev.Body {
+		t.Errorf("unexpected redaction for other bucket")
+	}
+}
+
+func TestPolicyEventBinaryBody(t *testing.T) {
+	p, err := NewPolicy(&Config{Rules: Rules{CardNumbers: true}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ev := types.Event{}
+	ev.SetRawBody(append([]byte{0xff, 0xfe}, []byte(" card=4111111111111111")...))
+
+	redacted := p.Event(ev)
+	raw, err := redacted.RawBody()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(string(raw), "card="+Mask) {
+		t.Errorf("expected card number to be redacted in binary body, got: %q", raw)
+	}
+}
@@ -0,0 +1,100 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/mailru/easyjson"
+
+	"github.com/webhookrelay/relay-go/pkg/recorder"
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// Reader - reads recorded events, one JSON encoded types.Event or
+// recorder archive record per line, gzip compressed input is detected.
+// Archive records written by a newer relayd are skipped
+type Reader struct {
+	r       *bufio.Reader
+	err     error
+	line    int
+	skipped int
+}
+
+// NewReader - creates new event reader
+func NewReader(r io.Reader) *Reader {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(2)
+	if bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return &Reader{err: fmt.Errorf("failed to read gzip stream: %s", err)}
+		}
+		br = bufio.NewReader(gz)
+	}
+	return &Reader{r: br}
+}
+
+var (
+	gzipMagic        = []byte{0x1f, 0x8b}
+	recordVersionKey = []byte(`"version"`)
+)
+
+// Next - returns next event, io.EOF when there are no more events. Blank
+// lines are skipped
+func (r *Reader) Next() (*types.Event, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if err == io.ErrUnexpectedEOF {
+			// archive file left behind by a crash, its last line is incomplete
+			return nil, io.EOF
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(line) == 0 && err == io.EOF {
+			return nil, io.EOF
+		}
+		r.line++
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			continue
+		}
+
+		if bytes.Contains(line, recordVersionKey) {
+			var rec recorder.Record
+			if uerr := json.Unmarshal(line, &rec); uerr == nil && rec.Version > 0 && rec.Event != nil {
+				if rec.Version > recorder.FormatVersion {
+					r.skipped++
+					if err == io.EOF {
+						return nil, io.EOF
+					}
+					continue
+				}
+				return rec.Event, nil
+			}
+		}
+
+		var event types.Event
+		if uerr := easyjson.Unmarshal(line, &event); uerr != nil {
+			return nil, fmt.Errorf("line %d: failed to unmarshal event: %s", r.line, uerr)
+		}
+		return &event, nil
+	}
+}
+
+// Skipped - number of archive records skipped because their format is
+// newer than this relayd understands
+func (r *Reader) Skipped() int {
+	return r.skipped
+}
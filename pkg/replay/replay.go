@@ -0,0 +1,154 @@
+package replay
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/webhookrelay/relay-go/pkg/forward"
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// Opts - replay options
+type Opts struct {
+	Forwarder forward.Forwarder
+	// Rate - maximum events per second, zero means no limit
+	Rate float64
+	// Concurrency - number of events forwarded at once, defaults to 1
+	Concurrency int
+	// Destination - optional destination for all events, overrides
+	// recorded output destinations
+	Destination string
+	// Speed - when set, original inter-arrival times (based on event
+	// receive time) are honoured, compressed by this factor: 1 replays in
+	// real time, 10 ten times faster. Zero sends events without delays
+	Speed float64
+}
+
+// Result - delivery result of a replayed event
+type Result struct {
+	ID string
+	// StatusCode - destination response status, zero if request failed
+	StatusCode int
+	Latency    time.Duration
+	// Error - failure reason when event was not delivered
+	Error string
+}
+
+// Run - forwards events read from r until there are no more events or
+// context is cancelled, returns delivery results in completion order
+func Run(ctx context.Context, r *Reader, opts *Opts) (*Report, error) {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	report := &Report{}
+	events := make(chan *types.Event)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for event := range events {
+				report.add(forwardEvent(opts.Forwarder, event))
+			}
+		}()
+	}
+
+	start := time.Now()
+	err := dispatch(ctx, r, opts, events)
+	close(events)
+	wg.Wait()
+	report.Duration = time.Since(start)
+	report.Skipped = r.Skipped()
+
+	return report, err
+}
+
+// dispatch - reads events and sends them to workers on schedule
+func dispatch(ctx context.Context, r *Reader, opts *Opts, events chan<- *types.Event) error {
+	var interval time.Duration
+	if opts.Rate > 0 {
+		interval = time.Duration(float64(time.Second) / opts.Rate)
+	}
+
+	var (
+		start, next time.Time
+		first       time.Time
+	)
+	for {
+		event, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if opts.Destination != "" {
+			event.Meta.OutputDestination = opts.Destination
+		}
+
+		now := time.Now()
+		if start.IsZero() {
+			start = now
+		}
+		due := next
+		if opts.Speed > 0 && !event.Meta.ReceivedAt.IsZero() {
+			if first.IsZero() {
+				first = event.Meta.ReceivedAt
+			}
+			offset := time.Duration(float64(event.Meta.ReceivedAt.Sub(first)) / opts.Speed)
+			if at := start.Add(offset); at.After(due) {
+				due = at
+			}
+		}
+		if err := sleepUntil(ctx, due); err != nil {
+			return err
+		}
+		if interval > 0 {
+			next = time.Now().Add(interval)
+		}
+
+		select {
+		case events <- event:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func sleepUntil(ctx context.Context, t time.Time) error {
+	d := time.Until(t)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func forwardEvent(forwarder forward.Forwarder, event *types.Event) *Result {
+	result := &Result{ID: event.Meta.ID}
+
+	start := time.Now()
+	resp, err := forwarder.Forward(*event)
+	result.Latency = time.Since(start)
+
+	switch {
+	case err != nil:
+		result.Error = err.Error()
+	case resp.StatusCode == 0:
+		result.Error = string(resp.ResponseBody)
+	default:
+		result.StatusCode = resp.StatusCode
+	}
+	return result
+}
@@ -0,0 +1,202 @@
+package replay
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mailru/easyjson"
+
+	"github.com/webhookrelay/relay-go/pkg/recorder"
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+type recordingForwarder struct {
+	mu     sync.Mutex
+	events []types.Event
+	times  []time.Time
+}
+
+func (f *recordingForwarder) Forward(wh types.Event) (*types.LogUpdateRequest, error) {
+	f.mu.Lock()
+	f.events = append(f.events, wh)
+	f.times = append(f.times, time.Now())
+	f.mu.Unlock()
+
+	if wh.Meta.ID == "fail" {
+		return &types.LogUpdateRequest{ID: wh.Meta.ID, Status: types.RequestStatusFailed, ResponseBody: []byte("connection refused")}, nil
+	}
+	return &types.LogUpdateRequest{ID: wh.Meta.ID, StatusCode: 200}, nil
+}
+
+func recording(t *testing.T, events ...*types.Event) *Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, event := range events {
+		bts, err := easyjson.Marshal(event)
+		if err != nil {
+			t.Fatalf("failed to marshal event: %s", err)
+		}
+		buf.Write(bts)
+		buf.WriteString("\n\n")
+	}
+	return NewReader(&buf)
+}
+
+func TestReader(t *testing.T) {
+	r := recording(t,
+		&types.Event{Type: "webhook", Meta: types.EventMeta{ID: "1"}},
+		&types.Event{Type: "webhook", Meta: types.EventMeta{ID: "2"}},
+	)
+	for _, id := range []string{"1", "2"} {
+		ev, err := r.Next()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if ev.Meta.ID != id {
+			t.Errorf("expected event %s, got %s", id, ev.Meta.ID)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("expected EOF, got: %v", err)
+	}
+
+	r = NewReader(strings.NewReader("{}\nnot json\n"))
+	r.Next()
+	if _, err := r.Next(); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected line number in error, got: %v", err)
+	}
+}
+
+func TestReaderArchive(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	for _, id := range []string{"1", "newer", "2", "newer"} {
+		version := recorder.FormatVersion
+		if id == "newer" {
+			// written by a newer relayd
+			version++
+		}
+		bts, err := json.Marshal(&recorder.Record{
+			Version: version,
+			Event: &types.Event{
+				Type:   "webhook",
+				Method: "POST",
+				Body:   "{}",
+				Meta:   types.EventMeta{ID: id, OutputDestination: "http://prod.internal/hook"},
+			},
+			Result: &types.LogUpdateRequest{StatusCode: 200},
+		})
+		if err != nil {
+			t.Fatalf("failed to marshal record: %s", err)
+		}
+		gz.Write(append(bts, '\n'))
+	}
+	gz.Close()
+
+	r := NewReader(&buf)
+	for _, id := range []string{"1", "2"} {
+		ev, err := r.Next()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if ev.Meta.ID != id || ev.Meta.OutputDestination != "http://prod.internal/hook" {
+			t.Errorf("unexpected event: %+v", ev.Meta)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("expected EOF, got: %v", err)
+	}
+	if r.Skipped() != 2 {
+		t.Errorf("expected 2 skipped records, got: %d", r.Skipped())
+	}
+}
+
+func TestRunDestinationOverride(t *testing.T) {
+	var events []*types.Event
+	for i := 0; i < 10; i++ {
+		events = append(events, &types.Event{Meta: types.EventMeta{ID: fmt.Sprint(i), OutputDestination: "http://prod.internal/hook"}})
+	}
+	events = append(events, &types.Event{Meta: types.EventMeta{ID: "fail", OutputDestination: "http://prod.internal/hook"}})
+
+	f := &recordingForwarder{}
+	report, err := Run(context.Background(), recording(t, events...), &Opts{
+		Forwarder:   f,
+		Concurrency: 4,
+		Destination: "http://localhost:8080/hook",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(report.Results) != 11 || report.Failed() != 1 {
+		t.Errorf("expected 11 results with 1 failure, got %d and %d", len(report.Results), report.Failed())
+	}
+	for _, ev := range f.events {
+		if ev.Meta.OutputDestination != "http://localhost:8080/hook" {
+			t.Errorf("destination was not overridden: %s", ev.Meta.OutputDestination)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := report.Write(&out); err != nil {
+		t.Fatalf("failed to write report: %s", err)
+	}
+	for _, expected := range []string{"STATUS", "200", "error", "replayed 11 events", "1x connection refused"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in report:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestRunTiming(t *testing.T) {
+	received := time.Now()
+	r := recording(t,
+		&types.Event{Meta: types.EventMeta{ID: "1", ReceivedAt: received}},
+		&types.Event{Meta: types.EventMeta{ID: "2", ReceivedAt: received.Add(2 * time.Second)}},
+		&types.Event{Meta: types.EventMeta{ID: "3", ReceivedAt: received.Add(2 * time.Second)}},
+	)
+
+	f := &recordingForwarder{}
+	_, err := Run(context.Background(), r, &Opts{
+		Forwarder: f,
+		Speed:     10,
+		Rate:      10,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// 2s compressed ten times, then rate limit spaces out events arriving at once
+	if gap := f.times[1].Sub(f.times[0]); gap < 180*time.Millisecond || gap > time.Second {
+		t.Errorf("expected ~200ms between events, got %s", gap)
+	}
+	if gap := f.times[2].Sub(f.times[1]); gap < 90*time.Millisecond {
+		t.Errorf("expected rate limit to space events, got %s", gap)
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	received := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	r := recording(t,
+		&types.Event{Meta: types.EventMeta{ID: "1", ReceivedAt: received}},
+		&types.Event{Meta: types.EventMeta{ID: "2", ReceivedAt: received.Add(time.Hour)}},
+	)
+	_, err := Run(ctx, r, &Opts{
+		Forwarder: &recordingForwarder{},
+		Speed:     1,
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected deadline error, got: %v", err)
+	}
+}
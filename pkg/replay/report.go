@@ -0,0 +1,111 @@
+package replay
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Report - results of a replay
+type Report struct {
+	mu      sync.Mutex
+	Results []*Result
+	// Duration - total replay time
+	Duration time.Duration
+	// Skipped - archive records in a newer format that were not replayed
+	Skipped int
+}
+
+func (r *Report) add(result *Result) {
+	r.mu.Lock()
+	r.Results = append(r.Results, result)
+	r.mu.Unlock()
+}
+
+// Failed - number of events that were not delivered
+func (r *Report) Failed() int {
+	var failed int
+	for _, result := range r.Results {
+		if result.StatusCode == 0 {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Write - writes a table of results grouped by status code with latency
+// percentiles
+func (r *Report) Write(w io.Writer) error {
+	groups := make(map[string][]time.Duration)
+	failures := make(map[string]int)
+	for _, result := range r.Results {
+		status := "error"
+		if result.StatusCode != 0 {
+			status = strconv.Itoa(result.StatusCode)
+		} else {
+			failures[result.Error]++
+		}
+		groups[status] = append(groups[status], result.Latency)
+	}
+
+	statuses := make([]string, 0, len(groups))
+	for status := range groups {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tCOUNT\tMIN\tP50\tP95\tMAX")
+	for _, status := range statuses {
+		latencies := groups[status]
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\n",
+			status,
+			len(latencies),
+			round(latencies[0]),
+			round(percentile(latencies, 50)),
+			round(percentile(latencies, 95)),
+			round(latencies[len(latencies)-1]),
+		)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nreplayed %d events in %s, %d failed\n", len(r.Results), round(r.Duration), r.Failed())
+	if r.Skipped > 0 {
+		fmt.Fprintf(w, "skipped %d records written by a newer relayd\n", r.Skipped)
+	}
+	reasons := make([]string, 0, len(failures))
+	for reason := range failures {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(w, "  %dx %s\n", failures[reason], reason)
+	}
+	return nil
+}
+
+// percentile - nearest-rank percentile of sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	idx := (len(sorted)*p+99)/100 - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
+
+func round(d time.Duration) time.Duration {
+	switch {
+	case d > time.Second:
+		return d.Round(time.Millisecond)
+	case d > time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	}
+	return d.Round(time.Microsecond)
+}
@@ -0,0 +1,259 @@
+// Package respond answers webhooks with canned responses instead of
+// forwarding them, for demos and integration tests without a destination
+// service.
+package respond
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/webhookrelay/relay-go/pkg/forward"
+	"github.com/webhookrelay/relay-go/pkg/logger"
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// defaults
+const (
+	DefaultStatus        = http.StatusOK
+	DefaultFailureStatus = http.StatusInternalServerError
+)
+
+// Route - canned response for matching webhooks, empty match fields
+// match all webhooks. Example:
+//
+//	respond:
+//	  - bucket: github
+//	    path: /hooks/*
+//	    status: 202
+//	    headers:
+//	      Content-Type: application/json
+//	    body: '{"received": "{{ .Meta.ID }}", "action": "{{ .JSON.action }}"}'
+//	    latency: 100ms
+//	    jitter: 50ms
+//	    failure_rate: 0.1
+type Route struct {
+	// Bucket - bucket name or ID
+	Bucket string `yaml:"bucket"`
+	// Output - output name
+	Output string `yaml:"output"`
+	// Method - request method
+	Method string `yaml:"method"`
+	// Path - destination path, supports * wildcards
+	Path string `yaml:"path"`
+
+	// Status - response status, defaults to 200
+	Status  int               `yaml:"status"`
+	Headers map[string]string `yaml:"headers"`
+	// Body - response body template, see Request for available fields
+	Body string `yaml:"body"`
+
+	// Latency - delay before responding, plus random Jitter
+	Latency time.Duration `yaml:"latency"`
+	Jitter  time.Duration `yaml:"jitter"`
+	// FailureRate - fraction of webhooks, from 0 to 1, that get
+	// FailureStatus (500 by default) instead
+	FailureRate   float64 `yaml:"failure_rate"`
+	FailureStatus int     `yaml:"failure_status"`
+
+	body *template.Template
+}
+
+// Request - webhook fields available in body templates, e.g.
+// {{ .Method }}, {{ .Headers.Get "X-Github-Event" }} or {{ .JSON.action }}
+type Request struct {
+	Method  string
+	Path    string
+	Query   url.Values
+	Headers http.Header
+	Body    string
+	// JSON - decoded body, nil when body is not JSON
+	JSON interface{}
+	Meta types.EventMeta
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		bts, err := json.Marshal(v)
+		return string(bts), err
+	},
+	"now": func() string { return time.Now().UTC().Format(time.RFC3339) },
+}
+
+// Opts - responder configuration
+type Opts struct {
+	// Routes - checked in order, the first matching route responds.
+	// Webhooks without a matching route get 404
+	Routes []*Route
+	Logger *zap.SugaredLogger
+}
+
+var _ forward.Forwarder = &Responder{}
+
+// Responder - forwarder that responds to webhooks itself
+type Responder struct {
+	routes []*Route
+	logger *zap.SugaredLogger
+
+	mu     sync.Mutex
+	random *rand.Rand
+}
+
+// New - creates responder, returns an error for invalid routes
+func New(opts *Opts) (*Responder, error) {
+	if opts.Logger == nil {
+		opts.Logger = logger.GetLoggerInstance(logger.DefaultLogLevel).Sugar()
+	}
+
+	for idx, route := range opts.Routes {
+		if err := route.compile(); err != nil {
+			return nil, fmt.Errorf("route %d: %s", idx, err)
+		}
+	}
+
+	return &Responder{
+		routes: opts.Routes,
+		logger: opts.Logger,
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+func (r *Route) compile() error {
+	if r.Status == 0 {
+		r.Status = DefaultStatus
+	}
+	if r.FailureStatus == 0 {
+		r.FailureStatus = DefaultFailureStatus
+	}
+	if r.Status < 100 || r.Status > 599 || r.FailureStatus < 100 || r.FailureStatus > 599 {
+		return fmt.Errorf("invalid status code")
+	}
+	if r.FailureRate < 0 || r.FailureRate > 1 {
+		return fmt.Errorf("failure_rate must be between 0 and 1")
+	}
+	if r.Latency < 0 || r.Jitter < 0 {
+		return fmt.Errorf("latency cannot be negative")
+	}
+	if _, err := path.Match(r.Path, ""); err != nil {
+		return fmt.Errorf("invalid path pattern: %s", err)
+	}
+
+	tmpl, err := template.New("body").Funcs(templateFuncs).Option("missingkey=zero").Parse(r.Body)
+	if err != nil {
+		return fmt.Errorf("invalid body template: %s", err)
+	}
+	r.body = tmpl
+	return nil
+}
+
+func (r *Route) match(wh *types.Event, destination *url.URL) bool {
+	if r.Bucket != "" && r.Bucket != wh.Meta.BucketName && r.Bucket != wh.Meta.BucketID {
+		return false
+	}
+	if r.Output != "" && r.Output != wh.Meta.OutputName {
+		return false
+	}
+	if r.Method != "" && !strings.EqualFold(r.Method, wh.Method) {
+		return false
+	}
+	if r.Path != "" {
+		if ok, _ := path.Match(r.Path, destination.Path); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Forward - responds to webhook with the first matching route
+func (r *Responder) Forward(wh types.Event) (*types.LogUpdateRequest, error) {
+	destination, err := url.Parse(wh.Meta.OutputDestination)
+	if err != nil {
+		destination = &url.URL{}
+	}
+
+	var route *Route
+	for _, candidate := range r.routes {
+		if candidate.match(&wh, destination) {
+			route = candidate
+			break
+		}
+	}
+	if route == nil {
+		return respond(&wh, http.StatusNotFound, nil, []byte("no mock response configured for this webhook")), nil
+	}
+
+	r.mu.Lock()
+	delay := route.Latency
+	if route.Jitter > 0 {
+		delay += time.Duration(r.random.Int63n(int64(route.Jitter)))
+	}
+	failed := route.FailureRate > 0 && r.random.Float64() < route.FailureRate
+	r.mu.Unlock()
+
+	time.Sleep(delay)
+
+	if failed {
+		return respond(&wh, route.FailureStatus, nil, []byte("simulated failure")), nil
+	}
+
+	var body bytes.Buffer
+	if err := route.body.Execute(&body, newRequest(&wh, destination)); err != nil {
+		r.logger.Warnw("failed to render mock response body",
+			"id", wh.Meta.ID,
+			"error", err,
+		)
+		return respond(&wh, http.StatusInternalServerError, nil, []byte(fmt.Sprintf("failed to render response body: %s", err))), nil
+	}
+
+	headers := make(http.Header, len(route.Headers))
+	for k, v := range route.Headers {
+		headers.Set(k, v)
+	}
+
+	r.logger.Infow("webhook answered with mock response",
+		"id", wh.Meta.ID,
+		"status_code", route.Status,
+		"method", wh.Method,
+	)
+	return respond(&wh, route.Status, headers, body.Bytes()), nil
+}
+
+func newRequest(wh *types.Event, destination *url.URL) *Request {
+	req := &Request{
+		Method:  wh.Method,
+		Path:    destination.Path,
+		Headers: http.Header(wh.Headers),
+		Meta:    wh.Meta,
+	}
+	req.Query, _ = url.ParseQuery(wh.RawQuery)
+
+	body, err := wh.RawBody()
+	if err != nil {
+		body = []byte(wh.Body)
+	}
+	req.Body = string(body)
+	if err := json.Unmarshal(body, &req.JSON); err != nil {
+		req.JSON = nil
+	}
+	return req
+}
+
+func respond(wh *types.Event, status int, headers http.Header, body []byte) *types.LogUpdateRequest {
+	return &types.LogUpdateRequest{
+		ID:              wh.Meta.ID,
+		StatusCode:      status,
+		Status:          types.RequestStatusFromCode(status),
+		ResponseHeaders: headers,
+		ResponseBody:    body,
+	}
+}
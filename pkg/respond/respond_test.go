@@ -0,0 +1,101 @@
+package respond
+
+import (
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+func TestResponderRoutes(t *testing.T) {
+	r, err := New(&Opts{Routes: []*Route{
+		{
+			Bucket:  "github",
+			Path:    "/hooks/*",
+			Status:  http.StatusAccepted,
+			Headers: map[string]string{"content-type": "application/json"},
+			Body:    `{"id":"{{ .Meta.ID }}","event":"{{ .Headers.Get "X-Github-Event" }}","ref":"{{ .Query.Get "ref" }}","repo":{{ json .JSON.repository }}}`,
+		},
+		{Bucket: "github", Body: "fallback {{ .Method }} {{ .Path }}"},
+	}})
+	if err != nil {
+		t.Fatalf("failed to create responder: %s", err)
+	}
+
+	resp, err := r.Forward(types.Event{
+		Method:   "POST",
+		Body:     `{"repository":{"name":"api"}}`,
+		RawQuery: "ref=main",
+		Headers:  map[string][]string{"X-Github-Event": {"push"}},
+		Meta:     types.EventMeta{ID: "ev-1", BucketName: "github", OutputDestination: "http://localhost/hooks/github"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if resp.ID != "ev-1" || resp.StatusCode != http.StatusAccepted || resp.Status != types.RequestStatusSent {
+		t.Errorf("unexpected result: %+v", resp)
+	}
+	if expected := `{"id":"ev-1","event":"push","ref":"main","repo":{"name":"api"}}`; string(resp.ResponseBody) != expected {
+		t.Errorf("expected body %s, got %s", expected, resp.ResponseBody)
+	}
+	if resp.ResponseHeaders.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected headers: %v", resp.ResponseHeaders)
+	}
+
+	resp, _ = r.Forward(types.Event{
+		Method: "POST",
+		Body:   "not json",
+		Meta:   types.EventMeta{ID: "ev-2", BucketName: "github", OutputDestination: "http://localhost/other"},
+	})
+	if resp.StatusCode != http.StatusOK || string(resp.ResponseBody) != "fallback POST /other" {
+		t.Errorf("unexpected fallback result: %d %s", resp.StatusCode, resp.ResponseBody)
+	}
+
+	resp, _ = r.Forward(types.Event{Method: "POST", Meta: types.EventMeta{ID: "ev-3", BucketName: "stripe"}})
+	if resp.StatusCode != http.StatusNotFound || resp.Status != types.RequestStatusFailed {
+		t.Errorf("expected 404 without a matching route, got %d", resp.StatusCode)
+	}
+}
+
+func TestResponderFailuresAndLatency(t *testing.T) {
+	r, err := New(&Opts{Routes: []*Route{{
+		Latency:     20 * time.Millisecond,
+		Jitter:      time.Millisecond,
+		FailureRate: 0.5,
+	}}})
+	if err != nil {
+		t.Fatalf("failed to create responder: %s", err)
+	}
+	r.random = rand.New(rand.NewSource(1))
+
+	var failures int
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		resp, _ := r.Forward(types.Event{Method: "POST", Meta: types.EventMeta{ID: "ev-1"}})
+		if resp.StatusCode == DefaultFailureStatus {
+			failures++
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("expected latency to be simulated, took %s", elapsed)
+	}
+	if failures == 0 || failures == 10 {
+		t.Errorf("expected some simulated failures, got %d", failures)
+	}
+}
+
+func TestInvalidRoutes(t *testing.T) {
+	for _, route := range []*Route{
+		{Status: 42},
+		{FailureRate: 2},
+		{Latency: -time.Second},
+		{Path: "["},
+		{Body: "{{ .Method "},
+	} {
+		if _, err := New(&Opts{Routes: []*Route{route}}); err == nil {
+			t.Errorf("expected an error for route %+v", route)
+		}
+	}
+}
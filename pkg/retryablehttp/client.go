@@ -106,6 +106,15 @@ func NewRequest(method, url string, rawBody interface{}) (*Request, error) {
 			}
 			contentLength = int64(len(buf))
 
+		// Strings are immutable so a new reader for every attempt shares
+		// the same backing memory without copying
+		case string:
+			str := rawBody.(string)
+			body = func() (io.Reader, error) {
+				return strings.NewReader(str), nil
+			}
+			contentLength = int64(len(str))
+
 		// If a bytes.Buffer we can read the underlying byte slice over and
 		// over
 		case *bytes.Buffer:
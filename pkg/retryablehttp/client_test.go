@@ -48,6 +48,25 @@ func TestRequest(t *testing.T) {
 	if req.ContentLength != 2 {
 		t.Fatalf("bad ContentLength: %d", req.ContentLength)
 	}
+
+	// Works with string body, which can be read on every attempt
+	req, err = NewRequest("POST", "/", "hello")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if req.ContentLength != 5 {
+		t.Fatalf("bad ContentLength: %d", req.ContentLength)
+	}
+	for i := 0; i < 2; i++ {
+		r, err := req.body()
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		bts, _ := ioutil.ReadAll(r)
+		if string(bts) != "hello" {
+			t.Fatalf("bad body: %s", bts)
+		}
+	}
 }
 
 // Since normal ways we would generate a Reader have special cases, use a
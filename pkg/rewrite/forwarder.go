@@ -0,0 +1,37 @@
+package rewrite
+
+import (
+	"fmt"
+
+	"github.com/webhookrelay/relay-go/pkg/forward"
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+var _ forward.Forwarder = &Forwarder{}
+
+// Forwarder - forwarder that rewrites webhook destinations
+type Forwarder struct {
+	next     forward.Forwarder
+	rewriter *Rewriter
+}
+
+// NewForwarder - wraps forwarder, webhooks are forwarded to rewritten
+// destinations
+func NewForwarder(next forward.Forwarder, rewriter *Rewriter) *Forwarder {
+	return &Forwarder{next: next, rewriter: rewriter}
+}
+
+// Forward - rewrites destination and forwards webhook, webhooks which
+// destination cannot be rendered are reported as failed
+func (f *Forwarder) Forward(wh types.Event) (*types.LogUpdateRequest, error) {
+	destination, err := f.rewriter.Rewrite(&wh)
+	if err != nil {
+		return &types.LogUpdateRequest{
+			ID:           wh.Meta.ID,
+			Status:       types.RequestStatusFailed,
+			ResponseBody: []byte(fmt.Sprintf("request failed, destination rewrite error: %s", err)),
+		}, nil
+	}
+	wh.Meta.OutputDestination = destination
+	return f.next.Forward(wh)
+}
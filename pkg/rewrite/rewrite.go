@@ -0,0 +1,215 @@
+// Package rewrite changes webhook destinations with templates before
+// they are forwarded, so local configuration can point outputs to hosts
+// that only exist where relayd runs.
+package rewrite
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// Rule - destination template for matching webhooks, empty match fields
+// match all webhooks. Example:
+//
+//	rewrite:
+//	  - bucket: github
+//	    destination: 'http://{{ env "HOST" }}:8080/hooks/{{ .Meta.BucketName }}'
+//	  - host: localhost
+//	    destination: 'http://app{{ if .Port }}:{{ .Port }}{{ end }}{{ .Path }}{{ if .RawQuery }}?{{ .RawQuery }}{{ end }}'
+type Rule struct {
+	// Bucket - bucket name or ID
+	Bucket string `yaml:"bucket"`
+	// Output - output name
+	Output string `yaml:"output"`
+	// Host - original destination host, with an optional port
+	Host string `yaml:"host"`
+	// Destination - new destination template, see Data for
+	// available fields
+	Destination string `yaml:"destination"`
+
+	destination *template.Template
+}
+
+// Data - fields available in destination templates, e.g.
+// {{ .Meta.BucketName }}, {{ .Headers.Get "X-Tenant" }}, {{ segment 0 }}
+// or {{ env "HOST" "localhost" }}
+type Data struct {
+	Meta    types.EventMeta
+	Headers http.Header
+	// Destination - original destination and its parts
+	Destination string
+	Scheme      string
+	Host        string
+	Port        string
+	Path        string
+	RawQuery    string
+	// Segments - non-empty path segments, also available
+	// with segment function
+	Segments []string
+}
+
+// sample - event used to check templates when they are compiled
+var sample = types.Event{
+	Meta: types.EventMeta{
+		ID:                "sample",
+		BucketID:          "sample",
+		BucketName:        "sample",
+		InputID:           "sample",
+		InputName:         "sample",
+		OutputName:        "sample",
+		OutputDestination: "http://localhost:8080/sample?sample=1",
+		ReceivedAt:        time.Unix(0, 0).UTC(),
+	},
+	Headers: map[string][]string{},
+}
+
+func templateFuncs(data *Data) template.FuncMap {
+	return template.FuncMap{
+		// env - environment variable, fails when it is not set
+		// and no default is given
+		"env": func(name string, def ...string) (string, error) {
+			if v, ok := os.LookupEnv(name); ok {
+				return v, nil
+			}
+			if len(def) > 0 {
+				return def[0], nil
+			}
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		},
+		// segment - path segment by index, empty when missing
+		"segment": func(idx int) string {
+			if data == nil || idx < 0 || idx >= len(data.Segments) {
+				return ""
+			}
+			return data.Segments[idx]
+		},
+	}
+}
+
+// Opts - rewriter configuration
+type Opts struct {
+	// Rules - checked in order, the first matching rule rewrites the
+	// destination. Webhooks without a matching rule are not changed
+	Rules []*Rule
+}
+
+// Rewriter - renders destinations from rules
+type Rewriter struct {
+	rules []*Rule
+}
+
+// New - creates rewriter, returns an error for invalid rules, including
+// templates that reference unset environment variables
+func New(opts *Opts) (*Rewriter, error) {
+	for idx, rule := range opts.Rules {
+		if rule == nil {
+			return nil, fmt.Errorf("rule %d is empty", idx)
+		}
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("rule %d: %s", idx, err)
+		}
+	}
+	return &Rewriter{rules: opts.Rules}, nil
+}
+
+func (r *Rule) compile() error {
+	if r.Destination == "" {
+		return fmt.Errorf("destination is required")
+	}
+	tmpl, err := template.New("destination").Funcs(templateFuncs(nil)).Option("missingkey=error").Parse(r.Destination)
+	if err != nil {
+		return fmt.Errorf("invalid destination template: %s", err)
+	}
+	r.destination = tmpl
+
+	if _, err := r.render(&sample); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *Rule) match(wh *types.Event, destination *url.URL) bool {
+	if r.Bucket != "" && r.Bucket != wh.Meta.BucketName && r.Bucket != wh.Meta.BucketID {
+		return false
+	}
+	if r.Output != "" && r.Output != wh.Meta.OutputName {
+		return false
+	}
+	if r.Host != "" && !strings.EqualFold(r.Host, destination.Host) && !strings.EqualFold(r.Host, destination.Hostname()) {
+		return false
+	}
+	return true
+}
+
+// render - executes destination template for the webhook
+func (r *Rule) render(wh *types.Event) (string, error) {
+	data := newData(wh)
+
+	tmpl, err := r.destination.Clone()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Funcs(templateFuncs(data)).Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render destination: %s", err)
+	}
+
+	destination := strings.TrimSpace(buf.String())
+	if destination == "" {
+		return "", fmt.Errorf("rendered destination is empty")
+	}
+	if _, err := url.Parse(destination); err != nil {
+		return "", fmt.Errorf("rendered destination is invalid: %s", err)
+	}
+	return destination, nil
+}
+
+func newData(wh *types.Event) *Data {
+	data := &Data{
+		Meta:        wh.Meta,
+		Headers:     http.Header(wh.Headers),
+		Destination: wh.Meta.OutputDestination,
+	}
+	if data.Headers == nil {
+		data.Headers = http.Header{}
+	}
+
+	u, err := url.Parse(wh.Meta.OutputDestination)
+	if err != nil {
+		return data
+	}
+	data.Scheme = u.Scheme
+	data.Host = u.Hostname()
+	data.Port = u.Port()
+	data.Path = u.EscapedPath()
+	data.RawQuery = u.RawQuery
+	for _, s := range strings.Split(u.Path, "/") {
+		if s != "" {
+			data.Segments = append(data.Segments, s)
+		}
+	}
+	return data
+}
+
+// Rewrite - returns new destination from the first matching rule, the
+// original destination is returned when no rule matches
+func (r *Rewriter) Rewrite(wh *types.Event) (string, error) {
+	destination, err := url.Parse(wh.Meta.OutputDestination)
+	if err != nil {
+		destination = &url.URL{}
+	}
+	for _, rule := range r.rules {
+		if rule.match(wh, destination) {
+			return rule.render(wh)
+		}
+	}
+	return wh.Meta.OutputDestination, nil
+}
@@ -0,0 +1,108 @@
+package rewrite
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+type recordingForwarder struct {
+	destinations []string
+}
+
+func (f *recordingForwarder) Forward(wh types.Event) (*types.LogUpdateRequest, error) {
+	f.destinations = append(f.destinations, wh.Meta.OutputDestination)
+	return &types.LogUpdateRequest{ID: wh.Meta.ID, StatusCode: 200}, nil
+}
+
+func TestRewrite(t *testing.T) {
+	os.Setenv("RELAY_TEST_REWRITE_HOST", "relay-host")
+	defer os.Unsetenv("RELAY_TEST_REWRITE_HOST")
+
+	r, err := New(&Opts{Rules: []*Rule{
+		{Bucket: "github", Destination: `http://{{ env "RELAY_TEST_REWRITE_HOST" }}:8080/hooks/{{ .Meta.BucketName }}`},
+		{Host: "localhost", Destination: `http://app{{ if .Port }}:{{ .Port }}{{ end }}{{ .Path }}{{ if .RawQuery }}?{{ .RawQuery }}{{ end }}`},
+		{Host: "api.internal:8443", Destination: `https://{{ .Headers.Get "X-Tenant" }}.internal/{{ segment 1 }}/{{ segment 5 }}`},
+		{Output: "legacy", Destination: `http://{{ env "RELAY_TEST_REWRITE_MISSING" "fallback" }}{{ .Path }}`},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tenant := map[string][]string{"X-Tenant": {"acme"}}
+	cases := []struct {
+		event    *types.Event
+		expected string
+	}{
+		{&types.Event{Meta: types.EventMeta{BucketName: "github", OutputDestination: "http://localhost:3000/webhooks"}}, "http://relay-host:8080/hooks/github"},
+		// bucket renamed, matched by ID
+		{&types.Event{Meta: types.EventMeta{BucketName: "renamed", BucketID: "github", OutputDestination: "http://example.com"}}, "http://relay-host:8080/hooks/renamed"},
+		{&types.Event{Meta: types.EventMeta{BucketName: "stripe", OutputDestination: "http://localhost:3000/webhooks/stripe?x=1"}}, "http://app:3000/webhooks/stripe?x=1"},
+		{&types.Event{Meta: types.EventMeta{BucketName: "stripe", OutputDestination: "http://LOCALHOST/webhooks"}}, "http://app/webhooks"},
+		{&types.Event{Headers: tenant, Meta: types.EventMeta{BucketName: "stripe", OutputDestination: "https://api.internal:8443/v1/events"}}, "https://acme.internal/events/"},
+		{&types.Event{Meta: types.EventMeta{BucketName: "stripe", OutputDestination: "https://api.internal/v1/events"}}, "https://api.internal/v1/events"},
+		{&types.Event{Meta: types.EventMeta{BucketName: "billing", OutputName: "legacy", OutputDestination: "http://old:9000/legacy"}}, "http://fallback/legacy"},
+		{&types.Event{Meta: types.EventMeta{BucketName: "stripe", OutputDestination: "svc-a/github"}}, "svc-a/github"},
+	}
+	for _, c := range cases {
+		destination, err := r.Rewrite(c.event)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", c.event.Meta.OutputDestination, err)
+			continue
+		}
+		if destination != c.expected {
+			t.Errorf("%s: expected '%s', got '%s'", c.event.Meta.OutputDestination, c.expected, destination)
+		}
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	os.Unsetenv("RELAY_TEST_REWRITE_MISSING")
+
+	for _, rule := range []*Rule{
+		{},
+		{Destination: "http://{{ .Meta.Missing }}"},
+		{Destination: "http://{{ .Meta.ID "},
+		{Destination: `http://{{ env "RELAY_TEST_REWRITE_MISSING" }}/hooks`},
+		{Destination: "{{ if false }}x{{ end }}"},
+		{Destination: "http://host/%zz"},
+	} {
+		if _, err := New(&Opts{Rules: []*Rule{rule}}); err == nil {
+			t.Errorf("expected an error for '%s'", rule.Destination)
+		}
+	}
+}
+
+func TestForwarder(t *testing.T) {
+	os.Unsetenv("RELAY_TEST_REWRITE_MISSING")
+
+	r, err := New(&Opts{Rules: []*Rule{
+		{Bucket: "github", Destination: `http://app/{{ .Headers.Get "X-Missing" }}`},
+		{Bucket: "broken", Destination: `http://{{ if eq .Meta.BucketName "broken" }}{{ env "RELAY_TEST_REWRITE_MISSING" }}{{ end }}`},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	next := &recordingForwarder{}
+	f := NewForwarder(next, r)
+
+	if _, err := f.Forward(types.Event{Meta: types.EventMeta{ID: "wh-1", BucketName: "github", OutputDestination: "http://localhost/hooks"}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(next.destinations) != 1 || next.destinations[0] != "http://app/" {
+		t.Errorf("unexpected destinations: %v", next.destinations)
+	}
+
+	result, err := f.Forward(types.Event{Meta: types.EventMeta{ID: "wh-2", BucketName: "broken", OutputDestination: "http://localhost/hooks"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Status != types.RequestStatusFailed || !strings.Contains(string(result.ResponseBody), "destination rewrite error") {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if len(next.destinations) != 1 {
+		t.Errorf("webhook with invalid destination was forwarded")
+	}
+}
@@ -0,0 +1,156 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// DefaultExecTimeout - default command timeout
+var DefaultExecTimeout = time.Minute
+
+// maxOutput - command output reported back to Webhook Relay
+const maxOutput = 1 << 20
+
+// HeaderExitCode - response header with command exit code
+const HeaderExitCode = "X-Exit-Code"
+
+// execSink - runs a command for every webhook with body on stdin and
+// metadata in RELAY_* environment variables. Exit code 0 maps to 200,
+// other exit codes to 500, timeouts to 504 and commands that cannot be
+// started to 502. Standard output is reported as response body
+type execSink struct {
+	command string
+	args    []string
+	timeout time.Duration
+	logger  *zap.SugaredLogger
+}
+
+func newExecSink(u *url.URL, logger *zap.SugaredLogger) (*execSink, error) {
+	s := &execSink{
+		command: location(u),
+		args:    u.Query()["arg"],
+		timeout: DefaultExecTimeout,
+		logger:  logger,
+	}
+	if s.command == "" {
+		return nil, fmt.Errorf("exec sink requires a command")
+	}
+	if t := u.Query().Get("timeout"); t != "" {
+		timeout, err := time.ParseDuration(t)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid exec timeout '%s'", t)
+		}
+		s.timeout = timeout
+	}
+	return s, nil
+}
+
+func (s *execSink) Forward(wh types.Event) (*types.LogUpdateRequest, error) {
+	body, err := wh.RawBody()
+	if err != nil {
+		return result(&wh, http.StatusBadRequest, []byte(fmt.Sprintf("invalid webhook body: %s", err))), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	var stdout, stderr limitedBuffer
+	cmd := exec.CommandContext(ctx, s.command, s.args...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), environment(&wh)...)
+
+	start := time.Now()
+	err = cmd.Run()
+	took := time.Since(start)
+
+	exitCode := 0
+	status := http.StatusOK
+	output := stdout.Bytes()
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		exitCode = -1
+		status = http.StatusGatewayTimeout
+		output = append(output, fmt.Sprintf("\ncommand timed out after %s\n", s.timeout)...)
+	case err != nil:
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return result(&wh, http.StatusBadGateway, []byte(fmt.Sprintf("failed to run command: %s", err))), nil
+		}
+		exitCode = exitErr.ExitCode()
+		status = http.StatusInternalServerError
+	}
+	if status != http.StatusOK && stderr.Len() > 0 {
+		output = append(output, stderr.Bytes()...)
+	}
+
+	s.logger.Infow("webhook passed to command",
+		"id", wh.Meta.ID,
+		"command", s.command,
+		"exit_code", exitCode,
+		"duration", took,
+	)
+
+	resp := result(&wh, status, output)
+	resp.ResponseHeaders = http.Header{HeaderExitCode: []string{strconv.Itoa(exitCode)}}
+	return resp, nil
+}
+
+// environment - webhook metadata for the command, headers are passed as
+// RELAY_HEADER_<NAME> with dashes replaced by underscores
+func environment(wh *types.Event) []string {
+	env := []string{
+		"RELAY_EVENT_ID=" + wh.Meta.ID,
+		"RELAY_BUCKET=" + wh.Meta.BucketName,
+		"RELAY_BUCKET_ID=" + wh.Meta.BucketID,
+		"RELAY_INPUT=" + wh.Meta.InputName,
+		"RELAY_INPUT_ID=" + wh.Meta.InputID,
+		"RELAY_OUTPUT=" + wh.Meta.OutputName,
+		"RELAY_DESTINATION=" + wh.Meta.OutputDestination,
+		"RELAY_METHOD=" + wh.Method,
+		"RELAY_QUERY=" + wh.RawQuery,
+	}
+	for name, values := range wh.Headers {
+		key := "RELAY_HEADER_" + strings.ToUpper(strings.Map(envRune, name))
+		env = append(env, key+"="+strings.Join(values, ","))
+	}
+	return env
+}
+
+func envRune(r rune) rune {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return r
+	}
+	return '_'
+}
+
+// limitedBuffer - keeps up to maxOutput bytes of command output
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := maxOutput - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	// discarding the rest so command doesn't block on a full pipe
+	return len(p), nil
+}
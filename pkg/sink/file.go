@@ -0,0 +1,135 @@
+package sink
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mailru/easyjson"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// writerSink - writes events as JSON lines
+type writerSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newWriterSink(w io.Writer) *writerSink {
+	return &writerSink{w: w}
+}
+
+func (s *writerSink) Forward(wh types.Event) (*types.LogUpdateRequest, error) {
+	bts, err := easyjson.Marshal(wh)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event: %s", err)
+	}
+
+	s.mu.Lock()
+	_, err = s.w.Write(append(bts, '\n'))
+	s.mu.Unlock()
+	if err != nil {
+		return result(&wh, http.StatusInternalServerError, []byte(fmt.Sprintf("failed to write event: %s", err))), nil
+	}
+	return result(&wh, http.StatusOK, nil), nil
+}
+
+// fileSink - appends events to a JSON lines file or writes each event to
+// its own file when path is a directory
+type fileSink struct {
+	mu   sync.Mutex
+	path string
+	dir  bool
+}
+
+func newFileSink(path string) (*fileSink, error) {
+	if path == "" {
+		return nil, fmt.Errorf("file sink requires a path")
+	}
+	s := &fileSink{path: filepath.Clean(path)}
+	if strings.HasSuffix(path, "/") {
+		s.dir = true
+	} else if info, err := os.Stat(path); err == nil && info.IsDir() {
+		s.dir = true
+	}
+	return s, nil
+}
+
+func (s *fileSink) Forward(wh types.Event) (*types.LogUpdateRequest, error) {
+	bts, err := easyjson.Marshal(wh)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event: %s", err)
+	}
+
+	path := s.path
+	if s.dir {
+		path = filepath.Join(s.path, fileName(&wh))
+		err = s.writeFile(path, bts)
+	} else {
+		err = s.append(append(bts, '\n'))
+	}
+	if err != nil {
+		return result(&wh, http.StatusInternalServerError, []byte(fmt.Sprintf("failed to write event: %s", err))), nil
+	}
+	return result(&wh, http.StatusOK, []byte("written to "+path)), nil
+}
+
+func (s *fileSink) append(bts []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// opening file for every event lets tools like logrotate move it
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(bts)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (s *fileSink) writeFile(path string, bts []byte) error {
+	if err := os.MkdirAll(s.path, 0700); err != nil {
+		return err
+	}
+	// writing to a temporary file first, so readers never see partial events
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, bts, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// fileName - event file name, ordered by receive time
+func fileName(wh *types.Event) string {
+	receivedAt := wh.Meta.ReceivedAt
+	if receivedAt.IsZero() {
+		receivedAt = time.Now()
+	}
+	name := receivedAt.UTC().Format("20060102T150405.000000000Z")
+	if id := strings.Map(safeRune, wh.Meta.ID); id != "" {
+		name += "-" + id
+	} else {
+		name += "-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return name + ".json"
+}
+
+// safeRune - keeps characters that are safe in file names
+func safeRune(r rune) rune {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		return r
+	}
+	return -1
+}
@@ -0,0 +1,274 @@
+// Package tail prints received webhooks to a terminal, optionally
+// forwarding them as well.
+package tail
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mailru/easyjson"
+
+	"github.com/webhookrelay/relay-go/pkg/forward"
+	"github.com/webhookrelay/relay-go/pkg/jsonpath"
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+// output formats
+const (
+	FormatPretty = "pretty"
+	FormatJSON   = "json"
+	FormatRaw    = "raw"
+)
+
+// Opts - printer configuration
+type Opts struct {
+	Out io.Writer
+	// Format - pretty (default), json (one event per line) or raw (bodies)
+	Format string
+	// Headers - only events with matching headers are printed, either
+	// Name (header is present) or Name=pattern with * wildcards
+	Headers []string
+	// Body - optional regular expression event body must match
+	Body string
+	// Extract - JSON paths printed from event bodies instead of the
+	// whole event, tab separated
+	Extract []string
+	// Forwarder - optional forwarder, events are forwarded after they are
+	// printed and results are reported back to Webhook Relay
+	Forwarder forward.Forwarder
+}
+
+type headerFilter struct {
+	name    string
+	pattern string
+}
+
+var _ forward.Forwarder = &Printer{}
+
+// Printer - forwarder that prints events
+type Printer struct {
+	opts    *Opts
+	headers []headerFilter
+	body    *regexp.Regexp
+	extract []*jsonpath.Path
+
+	mu sync.Mutex
+}
+
+// NewPrinter - creates printer, returns an error for invalid filters
+func NewPrinter(opts *Opts) (*Printer, error) {
+	switch opts.Format {
+	case "":
+		opts.Format = FormatPretty
+	case FormatPretty, FormatJSON, FormatRaw:
+	default:
+		return nil, fmt.Errorf("unknown format '%s'", opts.Format)
+	}
+
+	p := &Printer{opts: opts}
+	for _, h := range opts.Headers {
+		parts := strings.SplitN(h, "=", 2)
+		filter := headerFilter{name: http.CanonicalHeaderKey(strings.TrimSpace(parts[0]))}
+		if len(parts) == 2 {
+			filter.pattern = parts[1]
+			if _, err := path.Match(filter.pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid header pattern '%s': %s", h, err)
+			}
+		}
+		p.headers = append(p.headers, filter)
+	}
+	if opts.Body != "" {
+		re, err := regexp.Compile(opts.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body expression: %s", err)
+		}
+		p.body = re
+	}
+	for _, expr := range opts.Extract {
+		jp, err := jsonpath.Parse(expr)
+		if err != nil {
+			return nil, err
+		}
+		p.extract = append(p.extract, jp)
+	}
+	return p, nil
+}
+
+// Forward - prints webhook and forwards it if printer has a forwarder
+func (p *Printer) Forward(wh types.Event) (*types.LogUpdateRequest, error) {
+	body, err := wh.RawBody()
+	if err != nil {
+		body = []byte(wh.Body)
+	}
+	matched := p.match(&wh, body)
+
+	if matched {
+		p.print(&wh, body)
+	}
+	if p.opts.Forwarder == nil {
+		return nil, nil
+	}
+
+	start := time.Now()
+	resp, err := p.opts.Forwarder.Forward(wh)
+	if matched && p.opts.Format == FormatPretty {
+		p.printResult(&wh, resp, err, time.Since(start))
+	}
+	return resp, err
+}
+
+func (p *Printer) match(wh *types.Event, body []byte) bool {
+	for _, filter := range p.headers {
+		values, ok := http.Header(wh.Headers)[filter.name]
+		if !ok {
+			return false
+		}
+		if filter.pattern == "" {
+			continue
+		}
+		var found bool
+		for _, v := range values {
+			if ok, _ := path.Match(filter.pattern, v); ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if p.body != nil && !p.body.Match(body) {
+		return false
+	}
+	return true
+}
+
+func (p *Printer) print(wh *types.Event, body []byte) {
+	var buf bytes.Buffer
+
+	switch {
+	case len(p.extract) > 0:
+		writeExtracted(&buf, p.extract, body)
+	case p.opts.Format == FormatJSON:
+		bts, err := easyjson.Marshal(wh)
+		if err != nil {
+			fmt.Fprintf(&buf, "failed to marshal event: %s\n", err)
+			break
+		}
+		buf.Write(bts)
+		buf.WriteByte('\n')
+	case p.opts.Format == FormatRaw:
+		buf.Write(body)
+		if len(body) > 0 && body[len(body)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+	default:
+		writePretty(&buf, wh, body)
+	}
+
+	p.write(buf.Bytes())
+}
+
+func (p *Printer) printResult(wh *types.Event, resp *types.LogUpdateRequest, err error, took time.Duration) {
+	var line string
+	switch {
+	case err != nil:
+		line = fmt.Sprintf("<- %s failed: %s (%s)\n\n", wh.Meta.ID, err, took.Round(time.Millisecond))
+	case resp != nil:
+		line = fmt.Sprintf("<- %s %d %s (%s)\n\n", wh.Meta.ID, resp.StatusCode, resp.Status, took.Round(time.Millisecond))
+	default:
+		return
+	}
+	p.write([]byte(line))
+}
+
+func (p *Printer) write(bts []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.opts.Out.Write(bts)
+}
+
+func writePretty(buf *bytes.Buffer, wh *types.Event, body []byte) {
+	receivedAt := wh.Meta.ReceivedAt
+	if receivedAt.IsZero() {
+		receivedAt = time.Now()
+	}
+	destination := wh.Meta.OutputDestination
+	if wh.RawQuery != "" {
+		destination += "?" + wh.RawQuery
+	}
+
+	fmt.Fprintf(buf, "%s %s %s", receivedAt.Local().Format("15:04:05"), wh.Method, wh.Meta.BucketName)
+	if wh.Meta.InputName != "" {
+		fmt.Fprintf(buf, " (%s)", wh.Meta.InputName)
+	}
+	if destination != "" {
+		fmt.Fprintf(buf, " -> %s", destination)
+	}
+	fmt.Fprintf(buf, " [%s]\n", wh.Meta.ID)
+
+	names := make([]string, 0, len(wh.Headers))
+	for name := range wh.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range wh.Headers[name] {
+			fmt.Fprintf(buf, "%s: %s\n", name, v)
+		}
+	}
+
+	if len(body) > 0 {
+		buf.WriteByte('\n')
+		switch {
+		case json.Valid(body):
+			if err := json.Indent(buf, body, "", "  "); err != nil {
+				buf.Write(body)
+			}
+		case utf8.Valid(body):
+			buf.Write(body)
+		default:
+			fmt.Fprintf(buf, "[binary body, %d bytes]", len(body))
+		}
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+}
+
+// writeExtracted - writes values selected by paths, tab separated. Strings
+// are written as is, other values as JSON and multiple matches of a path
+// are comma separated
+func writeExtracted(buf *bytes.Buffer, paths []*jsonpath.Path, body []byte) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		doc = nil
+	}
+
+	for i, jp := range paths {
+		if i > 0 {
+			buf.WriteByte('\t')
+		}
+		for j, v := range jp.Get(doc) {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+			if s, ok := v.(string); ok {
+				buf.WriteString(s)
+				continue
+			}
+			bts, _ := json.Marshal(v)
+			buf.Write(bts)
+		}
+	}
+	buf.WriteByte('\n')
+}
@@ -0,0 +1,123 @@
+package tail
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mailru/easyjson"
+
+	"github.com/webhookrelay/relay-go/pkg/types"
+)
+
+type staticForwarder struct{}
+
+func (f *staticForwarder) Forward(wh types.Event) (*types.LogUpdateRequest, error) {
+	return &types.LogUpdateRequest{ID: wh.Meta.ID, StatusCode: 200, Status: types.RequestStatusSent}, nil
+}
+
+func TestPrinterPretty(t *testing.T) {
+	var out bytes.Buffer
+	p, err := NewPrinter(&Opts{Out: &out})
+	if err != nil {
+		t.Fatalf("failed to create printer: %s", err)
+	}
+
+	resp, err := p.Forward(types.Event{
+		Type:    "webhook",
+		Method:  "POST",
+		Body:    `{"action":"opened"}`,
+		Headers: map[string][]string{"X-Github-Event": {"push"}},
+		Meta:    types.EventMeta{ID: "1", BucketName: "github", OutputDestination: "http://localhost:8080/hook"},
+	})
+	if resp != nil || err != nil {
+		t.Errorf("printer without forwarder should not return a result, got %v, %v", resp, err)
+	}
+
+	for _, expected := range []string{"POST github -> http://localhost:8080/hook [1]", "X-Github-Event: push", "  \"action\": \"opened\""} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in output:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestPrinterFormats(t *testing.T) {
+	ev := types.Event{
+		Type:   "webhook",
+		Method: "POST",
+		Body:   `{"action":"opened"}`,
+		Meta:   types.EventMeta{ID: "1", BucketName: "github"},
+	}
+
+	var out bytes.Buffer
+	p, _ := NewPrinter(&Opts{Out: &out, Format: FormatJSON})
+	p.Forward(ev)
+	var printed types.Event
+	if err := easyjson.Unmarshal(out.Bytes(), &printed); err != nil || printed.Meta.ID != "1" {
+		t.Errorf("expected event JSON, got %s (%v)", out.String(), err)
+	}
+
+	out.Reset()
+	p, _ = NewPrinter(&Opts{Out: &out, Format: FormatRaw})
+	p.Forward(ev)
+	if out.String() != ev.Body+"\n" {
+		t.Errorf("expected raw body, got %s", out.String())
+	}
+
+	if _, err := NewPrinter(&Opts{Out: &out, Format: "yaml"}); err == nil {
+		t.Errorf("expected an error for unknown format")
+	}
+}
+
+func TestPrinterFilters(t *testing.T) {
+	var out bytes.Buffer
+	p, err := NewPrinter(&Opts{
+		Out:     &out,
+		Headers: []string{"x-github-event=pull_*"},
+		Body:    `"action":"opened"`,
+		Extract: []string{"$.repository.full_name", "$.labels[*].name", "$.missing"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create printer: %s", err)
+	}
+
+	body := `{"action":"opened","repository":{"full_name":"acme/api"},"labels":[{"name":"bug"},{"name":"p1"}]}`
+	p.Forward(types.Event{
+		Body:    body,
+		Headers: map[string][]string{"X-Github-Event": {"push"}},
+		Meta:    types.EventMeta{ID: "1"},
+	})
+	p.Forward(types.Event{
+		Body:    body,
+		Headers: map[string][]string{"X-Github-Event": {"pull_request"}},
+		Meta:    types.EventMeta{ID: "2"},
+	})
+
+	if out.String() != "acme/api\tbug,p1\t\n" {
+		t.Errorf("unexpected output: %q", out.String())
+	}
+
+	if _, err := NewPrinter(&Opts{Out: &out, Body: "("}); err == nil {
+		t.Errorf("expected an error for invalid body expression")
+	}
+}
+
+func TestPrinterForwards(t *testing.T) {
+	var out bytes.Buffer
+	p, _ := NewPrinter(&Opts{Out: &out, Forwarder: &staticForwarder{}, Headers: []string{"X-Missing"}})
+
+	ev := types.Event{Type: "webhook", Method: "POST", Meta: types.EventMeta{ID: "1"}}
+	resp, err := p.Forward(ev)
+	if err != nil || resp == nil || resp.StatusCode != 200 {
+		t.Errorf("expected filtered out event to be forwarded, got %v, %v", resp, err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected filtered out event not to be printed, got %s", out.String())
+	}
+
+	p, _ = NewPrinter(&Opts{Out: &out, Forwarder: &staticForwarder{}})
+	p.Forward(ev)
+	if !strings.Contains(out.String(), "<- 1 200 sent") {
+		t.Errorf("expected delivery result in output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,90 @@
+// Package tlsconfig builds TLS client configuration from CA bundles,
+// client certificates and version constraints.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Opts - TLS client configuration
+type Opts struct {
+	// CAFile - PEM encoded CA bundle used to verify servers, system
+	// roots are used when empty
+	CAFile string `yaml:"ca_file"`
+	// CertFile and KeyFile - PEM encoded client certificate and key
+	// for mutual TLS
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// ServerName - overrides server name used for SNI and certificate
+	// verification
+	ServerName string `yaml:"server_name"`
+	// MinVersion - minimum TLS version: 1.0, 1.1, 1.2 or 1.3
+	MinVersion string `yaml:"min_version"`
+	// Insecure - skip server certificate verification
+	Insecure bool `yaml:"insecure"`
+}
+
+// IsZero - returns true when no TLS settings are configured
+func (o *Opts) IsZero() bool {
+	return o == nil || *o == Opts{}
+}
+
+// New - creates TLS client config
+func New(opts *Opts) (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         opts.ServerName,
+		InsecureSkipVerify: opts.Insecure,
+	}
+
+	if opts.MinVersion != "" {
+		v, err := ParseVersion(opts.MinVersion)
+		if err != nil {
+			return nil, err
+		}
+		cfg.MinVersion = v
+	}
+
+	if opts.CAFile != "" {
+		pem, err := ioutil.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file '%s'", opts.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		if opts.CertFile == "" || opts.KeyFile == "" {
+			return nil, fmt.Errorf("both client certificate and key files must be set")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %s", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// ParseVersion - parses TLS version such as "1.2" or "TLS1.2"
+func ParseVersion(v string) (uint16, error) {
+	switch strings.TrimPrefix(strings.ToUpper(v), "TLS") {
+	case "1.0", "10":
+		return tls.VersionTLS10, nil
+	case "1.1", "11":
+		return tls.VersionTLS11, nil
+	case "1.2", "12":
+		return tls.VersionTLS12, nil
+	case "1.3", "13":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unknown TLS version '%s', supported versions: 1.0, 1.1, 1.2, 1.3", v)
+}
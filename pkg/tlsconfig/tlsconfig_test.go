@@ -0,0 +1,122 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSigned - writes self signed certificate and key into dir
+func writeSelfSigned(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "relayd-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %s", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %s", err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFile, keyFile
+}
+
+func TestNew(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeSelfSigned(t, dir)
+
+	cfg, err := New(&Opts{
+		CAFile:     certFile,
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		ServerName: "internal.corp",
+		MinVersion: "1.2",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.RootCAs == nil {
+		t.Errorf("expected CA pool to be set")
+	}
+	if len(cfg.Certificates) != 1 {
+		t.Errorf("expected client certificate to be loaded")
+	}
+	if cfg.ServerName != "internal.corp" {
+		t.Errorf("unexpected server name: %s", cfg.ServerName)
+	}
+	if cfg.MinVersion != tls.VersionTLS12 {
+		t.Errorf("unexpected min version: %x", cfg.MinVersion)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, _ := writeSelfSigned(t, dir)
+
+	cases := map[string]*Opts{
+		"missing CA file":  {CAFile: filepath.Join(dir, "missing.pem")},
+		"CA without certs": {CAFile: filepath.Join(dir, "key.pem")},
+		"cert without key": {CertFile: certFile},
+		"unknown version":  {MinVersion: "2.0"},
+	}
+	for name, opts := range cases {
+		if _, err := New(opts); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	cases := map[string]uint16{
+		"1.0":    tls.VersionTLS10,
+		"1.1":    tls.VersionTLS11,
+		"1.2":    tls.VersionTLS12,
+		"TLS1.3": tls.VersionTLS13,
+	}
+	for v, expected := range cases {
+		got, err := ParseVersion(v)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", v, err)
+		}
+		if got != expected {
+			t.Errorf("%s: expected %x, got %x", v, expected, got)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package types
+
+import (
+	"encoding/base64"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Body encodings
+const (
+	BodyEncodingText   = ""
+	BodyEncodingBase64 = "base64"
+)
+
+// RawBody - returns exact body bytes, decoding them according to
+// BodyEncoding
+func (e *Event) RawBody() ([]byte, error) {
+	switch e.BodyEncoding {
+	case BodyEncodingText:
+		return []byte(e.Body), nil
+	case BodyEncodingBase64:
+		bts, err := base64.StdEncoding.DecodeString(e.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode base64 body: %s", err)
+		}
+		return bts, nil
+	default:
+		return nil, fmt.Errorf("unknown body encoding '%s'", e.BodyEncoding)
+	}
+}
+
+// SetRawBody - sets body, binary data that is not valid UTF-8 is
+// base64 encoded so it survives JSON encoding
+func (e *Event) SetRawBody(body []byte) {
+	if utf8.Valid(body) {
+		e.Body = string(body)
+		e.BodyEncoding = BodyEncodingText
+		return
+	}
+	e.Body = base64.StdEncoding.EncodeToString(body)
+	e.BodyEncoding = BodyEncodingBase64
+}
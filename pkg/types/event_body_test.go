@@ -0,0 +1,64 @@
+package types
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/mailru/easyjson"
+)
+
+func TestEventBinaryBodyRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	for _, size := range []int{0, 1, 255, 4096, 65537} {
+		body := make([]byte, size)
+		rnd.Read(body)
+
+		var ev Event
+		ev.Type = "webhook"
+		ev.SetRawBody(body)
+
+		bts, err := easyjson.Marshal(&ev)
+		if err != nil {
+			t.Fatalf("failed to marshal: %s", err)
+		}
+
+		var decoded Event
+		err = easyjson.Unmarshal(bts, &decoded)
+		if err != nil {
+			t.Fatalf("failed to unmarshal: %s", err)
+		}
+
+		raw, err := decoded.RawBody()
+		if err != nil {
+			t.Fatalf("failed to decode body: %s", err)
+		}
+		if !bytes.Equal(raw, body) {
+			t.Errorf("size %d: body does not match after round trip", size)
+		}
+	}
+}
+
+func TestEventTextBody(t *testing.T) {
+	var ev Event
+	ev.SetRawBody([]byte(`{"hi": "there"}`))
+	if ev.BodyEncoding != BodyEncodingText || ev.Body != `{"hi": "there"}` {
+		t.Errorf("expected text body, got %s (%s)", ev.Body, ev.BodyEncoding)
+	}
+
+	bts, err := easyjson.Marshal(&ev)
+	if err != nil {
+		t.Fatalf("failed to marshal: %s", err)
+	}
+	if bytes.Contains(bts, []byte("body_encoding")) {
+		t.Errorf("body_encoding should be omitted for text bodies: %s", bts)
+	}
+}
+
+func TestEventUnknownBodyEncoding(t *testing.T) {
+	ev := Event{Body: "x", BodyEncoding: "gzip"}
+	if _, err := ev.RawBody(); err == nil {
+		t.Errorf("expected an error")
+	}
+}
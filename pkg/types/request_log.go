@@ -13,6 +13,11 @@ type LogUpdateRequest struct {
 	Retries         int           `json:"retries"`
 }
 
+// LogUpdateBatchRequest - multiple log updates sent in one request
+type LogUpdateBatchRequest struct {
+	Updates []*LogUpdateRequest `json:"updates"`
+}
+
 // RequestStatus - request status
 type RequestStatus int
 
@@ -188,3 +188,113 @@ func (v *LogUpdateRequest) UnmarshalJSON(data []byte) error {
 func (v *LogUpdateRequest) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson8e8f11baDecodeGithubComWebhookrelayRelayGoPkgTypes(l, v)
 }
+func easyjson8e8f11baDecodeGithubComWebhookrelayRelayGoPkgTypes1(in *jlexer.Lexer, out *LogUpdateBatchRequest) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeString()
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "updates":
+			if in.IsNull() {
+				in.Skip()
+				out.Updates = nil
+			} else {
+				in.Delim('[')
+				if out.Updates == nil {
+					if !in.IsDelim(']') {
+						out.Updates = make([]*LogUpdateRequest, 0, 8)
+					} else {
+						out.Updates = []*LogUpdateRequest{}
+					}
+				} else {
+					out.Updates = (out.Updates)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v9 *LogUpdateRequest
+					if in.IsNull() {
+						in.Skip()
+						v9 = nil
+					} else {
+						if v9 == nil {
+							v9 = new(LogUpdateRequest)
+						}
+						(*v9).UnmarshalEasyJSON(in)
+					}
+					out.Updates = append(out.Updates, v9)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson8e8f11baEncodeGithubComWebhookrelayRelayGoPkgTypes1(out *jwriter.Writer, in LogUpdateBatchRequest) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"updates\":"
+		out.RawString(prefix[1:])
+		if in.Updates == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
+			out.RawString("null")
+		} else {
+			out.RawByte('[')
+			for v10, v11 := range in.Updates {
+				if v10 > 0 {
+					out.RawByte(',')
+				}
+				if v11 == nil {
+					out.RawString("null")
+				} else {
+					(*v11).MarshalEasyJSON(out)
+				}
+			}
+			out.RawByte(']')
+		}
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v LogUpdateBatchRequest) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson8e8f11baEncodeGithubComWebhookrelayRelayGoPkgTypes1(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v LogUpdateBatchRequest) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson8e8f11baEncodeGithubComWebhookrelayRelayGoPkgTypes1(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *LogUpdateBatchRequest) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson8e8f11baDecodeGithubComWebhookrelayRelayGoPkgTypes1(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *LogUpdateBatchRequest) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson8e8f11baDecodeGithubComWebhookrelayRelayGoPkgTypes1(l, v)
+}
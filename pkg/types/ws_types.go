@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 type EventMeta struct {
 	ID                string `json:"id"`
 	BucketID          string `json:"bucked_id"`
@@ -8,6 +10,9 @@ type EventMeta struct {
 	InputName         string `json:"input_name"`
 	OutputName        string `json:"output_name"`
 	OutputDestination string `json:"output_destination"`
+	// ReceivedAt - when webhook was received, set by the client
+	// if server doesn't provide it
+	ReceivedAt time.Time `json:"received_at"`
 }
 
 // Event is used by the socket server to stream new webhooks, example:
@@ -46,11 +51,23 @@ type Event struct {
 	Headers  map[string][]string `json:"headers"`
 	RawQuery string              `json:"query"`
 	Body     string              `json:"body"`
-	Method   string              `json:"method"`
+	// BodyEncoding - empty for text bodies or "base64" for binary
+	// bodies, see RawBody
+	BodyEncoding string `json:"body_encoding,omitempty"`
+	Method       string `json:"method"`
 
 	// combined fields from status
 	Status  string `json:"status"`
 	Message string `json:"message"`
+
+	// RequestID - ID of the acknowledged action request, set on "ack" events
+	RequestID string `json:"request_id,omitempty"`
+
+	// APIVersion and Capabilities - server protocol version and supported
+	// features, set on "authenticated" status by servers that support
+	// the handshake
+	APIVersion   string   `json:"api_version,omitempty"`
+	Capabilities []string `json:"capabilities,omitempty"`
 }
 
 // SubscribeRequest contains bin ID
@@ -76,8 +93,36 @@ type ActionRequest struct {
 
 	// if action == subscribe
 	Buckets []string `json:"buckets"`
+
+	// if action == log_update, server acknowledges updates with
+	// an "ack" event carrying the same request ID
+	RequestID  string              `json:"request_id,omitempty"`
+	LogUpdates []*LogUpdateRequest `json:"log_updates,omitempty"`
+
+	// if action == auth, client version and supported features
+	Version      string   `json:"version,omitempty"`
+	APIVersion   string   `json:"api_version,omitempty"`
+	Capabilities []string `json:"capabilities,omitempty"`
+
+	// if action == ack, result of the acknowledged server request
+	Status string `json:"status,omitempty"`
 }
 
+// protocol capabilities, advertised by client and server during
+// authentication
+const (
+	// CapabilityCompression - permessage-deflate and gzipped log updates
+	CapabilityCompression = "compression"
+	// CapabilityBinaryBodies - base64 encoded event bodies
+	CapabilityBinaryBodies = "binary_bodies"
+	// CapabilityLogUpdates - log updates over websocket
+	CapabilityLogUpdates = "log_updates"
+	// CapabilityBatchLogUpdates - batched log updates over HTTP
+	CapabilityBatchLogUpdates = "batch_log_updates"
+	// CapabilityUnsubscribe - unsubscribing from buckets without reconnecting
+	CapabilityUnsubscribe = "unsubscribe"
+)
+
 type EventStatus struct {
 	ID         string `json:"id"`
 	StatusCode int    `json:"status_code"`
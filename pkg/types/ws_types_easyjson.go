@@ -137,6 +137,10 @@ func easyjson8df87204DecodeGithubComWebhookrelayRelayGoPkgTypes1(in *jlexer.Lexe
 			out.OutputName = string(in.String())
 		case "output_destination":
 			out.OutputDestination = string(in.String())
+		case "received_at":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.ReceivedAt).UnmarshalJSON(data))
+			}
 		default:
 			in.SkipRecursive()
 		}
@@ -186,6 +190,11 @@ func easyjson8df87204EncodeGithubComWebhookrelayRelayGoPkgTypes1(out *jwriter.Wr
 		out.RawString(prefix)
 		out.String(string(in.OutputDestination))
 	}
+	{
+		const prefix string = ",\"received_at\":"
+		out.RawString(prefix)
+		out.Raw((in.ReceivedAt).MarshalJSON())
+	}
 	out.RawByte('}')
 }
 
@@ -278,12 +287,41 @@ func easyjson8df87204DecodeGithubComWebhookrelayRelayGoPkgTypes2(in *jlexer.Lexe
 			out.RawQuery = string(in.String())
 		case "body":
 			out.Body = string(in.String())
+		case "body_encoding":
+			out.BodyEncoding = string(in.String())
 		case "method":
 			out.Method = string(in.String())
 		case "status":
 			out.Status = string(in.String())
 		case "message":
 			out.Message = string(in.String())
+		case "request_id":
+			out.RequestID = string(in.String())
+		case "api_version":
+			out.APIVersion = string(in.String())
+		case "capabilities":
+			if in.IsNull() {
+				in.Skip()
+				out.Capabilities = nil
+			} else {
+				in.Delim('[')
+				if out.Capabilities == nil {
+					if !in.IsDelim(']') {
+						out.Capabilities = make([]string, 0, 4)
+					} else {
+						out.Capabilities = []string{}
+					}
+				} else {
+					out.Capabilities = (out.Capabilities)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v15 string
+					v15 = string(in.String())
+					out.Capabilities = append(out.Capabilities, v15)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
 		default:
 			in.SkipRecursive()
 		}
@@ -350,6 +388,11 @@ func easyjson8df87204EncodeGithubComWebhookrelayRelayGoPkgTypes2(out *jwriter.Wr
 		out.RawString(prefix)
 		out.String(string(in.Body))
 	}
+	if in.BodyEncoding != "" {
+		const prefix string = ",\"body_encoding\":"
+		out.RawString(prefix)
+		out.String(string(in.BodyEncoding))
+	}
 	{
 		const prefix string = ",\"method\":"
 		out.RawString(prefix)
@@ -365,6 +408,30 @@ func easyjson8df87204EncodeGithubComWebhookrelayRelayGoPkgTypes2(out *jwriter.Wr
 		out.RawString(prefix)
 		out.String(string(in.Message))
 	}
+	if in.RequestID != "" {
+		const prefix string = ",\"request_id\":"
+		out.RawString(prefix)
+		out.String(string(in.RequestID))
+	}
+	if in.APIVersion != "" {
+		const prefix string = ",\"api_version\":"
+		out.RawString(prefix)
+		out.String(string(in.APIVersion))
+	}
+	if len(in.Capabilities) != 0 {
+		const prefix string = ",\"capabilities\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('[')
+			for v16, v17 := range in.Capabilities {
+				if v16 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v17))
+			}
+			out.RawByte(']')
+		}
+	}
 	out.RawByte('}')
 }
 
@@ -439,6 +506,68 @@ func easyjson8df87204DecodeGithubComWebhookrelayRelayGoPkgTypes3(in *jlexer.Lexe
 				}
 				in.Delim(']')
 			}
+		case "request_id":
+			out.RequestID = string(in.String())
+		case "log_updates":
+			if in.IsNull() {
+				in.Skip()
+				out.LogUpdates = nil
+			} else {
+				in.Delim('[')
+				if out.LogUpdates == nil {
+					if !in.IsDelim(']') {
+						out.LogUpdates = make([]*LogUpdateRequest, 0, 8)
+					} else {
+						out.LogUpdates = []*LogUpdateRequest{}
+					}
+				} else {
+					out.LogUpdates = (out.LogUpdates)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v9 *LogUpdateRequest
+					if in.IsNull() {
+						in.Skip()
+						v9 = nil
+					} else {
+						if v9 == nil {
+							v9 = new(LogUpdateRequest)
+						}
+						(*v9).UnmarshalEasyJSON(in)
+					}
+					out.LogUpdates = append(out.LogUpdates, v9)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "version":
+			out.Version = string(in.String())
+		case "api_version":
+			out.APIVersion = string(in.String())
+		case "capabilities":
+			if in.IsNull() {
+				in.Skip()
+				out.Capabilities = nil
+			} else {
+				in.Delim('[')
+				if out.Capabilities == nil {
+					if !in.IsDelim(']') {
+						out.Capabilities = make([]string, 0, 4)
+					} else {
+						out.Capabilities = []string{}
+					}
+				} else {
+					out.Capabilities = (out.Capabilities)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v12 string
+					v12 = string(in.String())
+					out.Capabilities = append(out.Capabilities, v12)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		case "status":
+			out.Status = string(in.String())
 		default:
 			in.SkipRecursive()
 		}
@@ -484,6 +613,58 @@ func easyjson8df87204EncodeGithubComWebhookrelayRelayGoPkgTypes3(out *jwriter.Wr
 			out.RawByte(']')
 		}
 	}
+	if in.RequestID != "" {
+		const prefix string = ",\"request_id\":"
+		out.RawString(prefix)
+		out.String(string(in.RequestID))
+	}
+	if len(in.LogUpdates) != 0 {
+		const prefix string = ",\"log_updates\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('[')
+			for v10, v11 := range in.LogUpdates {
+				if v10 > 0 {
+					out.RawByte(',')
+				}
+				if v11 == nil {
+					out.RawString("null")
+				} else {
+					(*v11).MarshalEasyJSON(out)
+				}
+			}
+			out.RawByte(']')
+		}
+	}
+	if in.Version != "" {
+		const prefix string = ",\"version\":"
+		out.RawString(prefix)
+		out.String(string(in.Version))
+	}
+	if in.APIVersion != "" {
+		const prefix string = ",\"api_version\":"
+		out.RawString(prefix)
+		out.String(string(in.APIVersion))
+	}
+	if len(in.Capabilities) != 0 {
+		const prefix string = ",\"capabilities\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('[')
+			for v13, v14 := range in.Capabilities {
+				if v13 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v14))
+			}
+			out.RawByte(']')
+		}
+	}
+	if in.Status != "" {
+		const prefix string = ",\"status\":"
+		out.RawString(prefix)
+		out.String(string(in.Status))
+	}
 	out.RawByte('}')
 }
 
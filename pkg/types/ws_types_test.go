@@ -0,0 +1,45 @@
+package types
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mailru/easyjson"
+)
+
+func TestHandshakeRoundTrip(t *testing.T) {
+	bts, err := easyjson.Marshal(&ActionRequest{
+		Action:       "auth",
+		Version:      "1.2.3",
+		APIVersion:   "1",
+		Capabilities: []string{CapabilityLogUpdates, CapabilityBinaryBodies},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal: %s", err)
+	}
+	var req ActionRequest
+	if err := easyjson.Unmarshal(bts, &req); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	}
+	if req.Version != "1.2.3" || req.APIVersion != "1" || len(req.Capabilities) != 2 {
+		t.Errorf("unexpected request: %+v", req)
+	}
+
+	bts, err = easyjson.Marshal(&Event{Type: "status", Status: "authenticated", APIVersion: "1", Capabilities: []string{CapabilityUnsubscribe}})
+	if err != nil {
+		t.Fatalf("failed to marshal: %s", err)
+	}
+	var ev Event
+	if err := easyjson.Unmarshal(bts, &ev); err != nil {
+		t.Fatalf("failed to unmarshal: %s", err)
+	}
+	if ev.APIVersion != "1" || len(ev.Capabilities) != 1 || ev.Capabilities[0] != CapabilityUnsubscribe {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+
+	// legacy messages don't carry handshake fields
+	bts, _ = easyjson.Marshal(&ActionRequest{Action: "pong"})
+	if bytes.Contains(bts, []byte("capabilities")) || bytes.Contains(bts, []byte("version")) {
+		t.Errorf("unexpected handshake fields: %s", bts)
+	}
+}